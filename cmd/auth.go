@@ -16,6 +16,9 @@ var (
 	authAccount  string
 	authScope    string
 	authAddScope []string
+	authBind     string
+	authManual   bool
+	authPreset   []string
 
 	// flags for auth add
 	authAddName    string
@@ -24,6 +27,12 @@ var (
 	authAddScopes  string
 	authAddDomains string
 	authAddLogin bool
+	authAddPreset  []string
+
+	authAddUserID    string
+	authAddSecretEnv string
+	authAddCertPath  string
+	authAddKeyPath   string
 )
 
 // authCmd represents the auth command
@@ -37,13 +46,27 @@ var authCmd = &cobra.Command{
 var authLoginCmd = &cobra.Command{
 	Use:   "login",
 	Short: "Login to account",
-	Long:  `Authenticate an account using the configured auth flow (devicecode or authcode).`,
+	Long:  `Authenticate an account using the configured auth flow (devicecode, authcode or clientcredentials).`,
 	Run: func(cmd *cobra.Command, args []string) {
-		if authAccount == "" {
+		account, err := pickAccount(authAccount)
+		if err != nil {
+			fatal(err)
+		}
+		if account == "" {
 			cmd.Help()
 			os.Exit(1)
 			return
 		}
+		authAccount = account
+		auth.CallbackBind = authBind
+		auth.ManualCode = authManual
+
+		// Presets add to the token's scopes like --add-scope
+		presetScopes, err := auth.PresetScopes(authPreset)
+		if err != nil {
+			fatal(err)
+		}
+		authAddScope = append(authAddScope, presetScopes...)
 
 		if err := auth.DispatchLogin(cfg, authAccount, authScope, authAddScope); err != nil {
 			fatal(err)
@@ -67,11 +90,16 @@ var authRefreshCmd = &cobra.Command{
 	Short: "Refresh token",
 	Long:  `Force refresh the access token for an account.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		if authAccount == "" {
+		account, err := pickAccount(authAccount)
+		if err != nil {
+			fatal(err)
+		}
+		if account == "" {
 			cmd.Help()
 			os.Exit(1)
 			return
 		}
+		authAccount = account
 
 		if err := auth.RefreshToken(cfg, authAccount); err != nil {
 			fatal(err)
@@ -85,11 +113,16 @@ var authScopesCmd = &cobra.Command{
 	Short: "Show token scopes",
 	Long:  `Display the scopes stored in the current token for an account.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		if authAccount == "" {
+		account, err := pickAccount(authAccount)
+		if err != nil {
+			fatal(err)
+		}
+		if account == "" {
 			cmd.Help()
 			os.Exit(1)
 			return
 		}
+		authAccount = account
 
 		if err := auth.ShowScopes(authAccount); err != nil {
 			fatal(err)
@@ -97,6 +130,29 @@ var authScopesCmd = &cobra.Command{
 	},
 }
 
+// authWhoamiCmd represents the auth whoami command
+var authWhoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Show the signed-in user",
+	Long:  `Fetch /me to confirm which identity the account's token belongs to. Requires User.Read.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		account, err := pickAccount(authAccount)
+		if err != nil {
+			fatal(err)
+		}
+		if account == "" {
+			cmd.Help()
+			os.Exit(1)
+			return
+		}
+		authAccount = account
+
+		if err := auth.WhoAmI(cfg, authAccount); err != nil {
+			fatal(err)
+		}
+	},
+}
+
 // authAddCmd represents the auth add command
 var authAddCmd = &cobra.Command{
 	Use:   "add",
@@ -145,11 +201,31 @@ func runAuthAdd() error {
 		if authFlow == "" {
 			authFlow = "devicecode"
 		}
-		if authFlow != "devicecode" && authFlow != "authcode" {
-			return fmt.Errorf("invalid --flow: must be 'devicecode' or 'authcode'")
+		if authFlow != "devicecode" && authFlow != "authcode" && authFlow != auth.FlowClientCredentials {
+			return fmt.Errorf("invalid --flow: must be 'devicecode', 'authcode' or 'clientcredentials'")
+		}
+		if authFlow == auth.FlowClientCredentials {
+			if authAddUserID == "" {
+				return fmt.Errorf("--flow clientcredentials needs --user-id (the mailbox to act on)")
+			}
+			if authAddSecretEnv == "" && authAddCertPath == "" {
+				return fmt.Errorf("--flow clientcredentials needs --client-secret-env or --cert-path")
+			}
+		}
+		if authAddSecretEnv != "" && authAddCertPath != "" {
+			return fmt.Errorf("--client-secret-env and --cert-path cannot be combined")
+		}
+		if authAddKeyPath != "" && authAddCertPath == "" {
+			return fmt.Errorf("--key-path needs --cert-path")
+		}
+
+		// Expand presets, then add scopes from flag (comma-separated)
+		presetScopes, err := auth.PresetScopes(authAddPreset)
+		if err != nil {
+			return err
 		}
+		scopeChoices = append(scopeChoices, presetScopes...)
 
-		// Parse scopes from flag (comma-separated)
 		if authAddScopes != "" {
 			for _, s := range strings.Split(authAddScopes, ",") {
 				scope := strings.TrimSpace(s)
@@ -157,7 +233,7 @@ func runAuthAdd() error {
 					scopeChoices = append(scopeChoices, scope)
 				}
 			}
-		} else {
+		} else if len(scopeChoices) == 0 {
 			// Default scopes if not specified
 			scopeChoices = []string{"Calendars.ReadWrite", "User.Read"}
 		}
@@ -257,6 +333,11 @@ func runAuthAdd() error {
 		Hint:     emailHint,
 		Scope:    scopeStr,
 		Domains:  domains,
+
+		ClientSecretEnv: authAddSecretEnv,
+		CertPath:        authAddCertPath,
+		KeyPath:         authAddKeyPath,
+		UserID:          authAddUserID,
 	}
 
 	if err := config.SaveAccount(accountName, account); err != nil {
@@ -270,6 +351,9 @@ func runAuthAdd() error {
 	if len(domains) > 0 {
 		fmt.Printf("  Domains: %s\n", strings.Join(domains, ", "))
 	}
+	if authAddUserID != "" {
+		fmt.Printf("  User: %s\n", authAddUserID)
+	}
 
 	// Login if confirmed
 	if loginNow {
@@ -279,30 +363,45 @@ func runAuthAdd() error {
 			return fmt.Errorf("failed to reload config: %w", err)
 		}
 		fmt.Println()
-		return auth.DispatchLogin(newCfg, accountName, "", nil)
+		if err := auth.DispatchLogin(newCfg, accountName, "", nil); err != nil {
+			return err
+		}
+
+		// Report scopes the tenant or app registration dropped
+		return auth.CheckGrantedScopes(accountName, scopeStr)
 	}
 
 	return nil
 }
 
 func init() {
-	authLoginCmd.Flags().StringVar(&authAccount, "account", "", "Account name (required)")
+	authLoginCmd.Flags().StringVar(&authAccount, "account", "", accountFlagHelp)
 	authLoginCmd.Flags().StringVar(&authScope, "scope", "", "Override config scope (full scope string)")
 	authLoginCmd.Flags().StringSliceVar(&authAddScope, "add-scope", []string{}, "Add scope(s) to existing token scopes")
-	authRefreshCmd.Flags().StringVar(&authAccount, "account", "", "Account name (required)")
-	authScopesCmd.Flags().StringVar(&authAccount, "account", "", "Account name (required)")
+	authLoginCmd.Flags().StringSliceVar(&authPreset, "scope-preset", []string{}, "Add preset scopes: calendar, contacts, mail, full (comma-separated)")
+	authLoginCmd.Flags().BoolVar(&authManual, "manual", false, "Authcode flow without a callback server: paste the redirected URL back in")
+	authLoginCmd.Flags().StringVar(&authBind, "bind", "", "Authcode callback listen address, e.g. 127.0.0.1:8400 for an SSH tunnel or 0.0.0.0:8400 (insecure)")
+	authRefreshCmd.Flags().StringVar(&authAccount, "account", "", accountFlagHelp)
+	authScopesCmd.Flags().StringVar(&authAccount, "account", "", accountFlagHelp)
+	authWhoamiCmd.Flags().StringVar(&authAccount, "account", "", accountFlagHelp)
 
 	// Flags for auth add (non-interactive mode)
 	authAddCmd.Flags().StringVar(&authAddName, "name", "", "Account name (required)")
 	authAddCmd.Flags().StringVar(&authAddHint, "hint", "", "Email hint (e.g., user@company.com)")
-	authAddCmd.Flags().StringVar(&authAddFlow, "flow", "devicecode", "Auth flow: devicecode or authcode")
+	authAddCmd.Flags().StringVar(&authAddFlow, "flow", "devicecode", "Auth flow: devicecode, authcode or clientcredentials")
 	authAddCmd.Flags().StringVar(&authAddScopes, "scopes", "", "Comma-separated scopes (e.g., Calendars.ReadWrite,User.Read)")
+	authAddCmd.Flags().StringSliceVar(&authAddPreset, "scope-preset", []string{}, "Scope presets: calendar, contacts, mail, full (comma-separated; combines with --scopes)")
 	authAddCmd.Flags().StringVar(&authAddDomains, "domains", "", "Comma-separated domains (e.g., company.com,subsidiary.com)")
 	authAddCmd.Flags().BoolVar(&authAddLogin, "login", false, "Auto-login after creating account")
+	authAddCmd.Flags().StringVar(&authAddUserID, "user-id", "", "Mailbox to act on instead of /me (required with --flow clientcredentials)")
+	authAddCmd.Flags().StringVar(&authAddSecretEnv, "client-secret-env", "", "Environment variable holding the client secret of a confidential app")
+	authAddCmd.Flags().StringVar(&authAddCertPath, "cert-path", "", "PEM certificate for certificate auth instead of a secret")
+	authAddCmd.Flags().StringVar(&authAddKeyPath, "key-path", "", "PEM private key for --cert-path (if not in the certificate file)")
 
 	authCmd.AddCommand(authLoginCmd)
 	authCmd.AddCommand(authStatusCmd)
 	authCmd.AddCommand(authRefreshCmd)
 	authCmd.AddCommand(authScopesCmd)
+	authCmd.AddCommand(authWhoamiCmd)
 	authCmd.AddCommand(authAddCmd)
 }
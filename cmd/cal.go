@@ -1,27 +1,59 @@
 package cmd
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/charmbracelet/huh"
 	"github.com/lcorneliussen/md365/internal/cal"
 	"os"
+	"github.com/mattn/go-isatty"
 	"github.com/spf13/cobra"
 )
 
 var (
-	calAccount   string
-	calFrom      string
-	calTo        string
-	calSearch    string
-	calSubject   string
-	calStart     string
-	calEnd       string
-	calLocation  string
-	calBody      string
-	calID        string
-	calFile      string
-	calAttendees []string
-	calForce     bool
+	calAccount      string
+	calFrom         string
+	calTo           string
+	calSearch       string
+	calRegex        bool
+	calGroupDay     bool
+	calStatus       string
+	calMineOnly     bool
+	calSubject      string
+	calStart        string
+	calEnd          string
+	calLocation     string
+	calBody         string
+	calID           string
+	calFile         string
+	calAttendees    []string
+	calForce        bool
+	calDuration     time.Duration
+	calInterval     int
+	calNotify       string
+	calComment      string
+	calNext         int
+	calRecurrence   string
+	calSensitivity  string
+	calFormat       string
+	calExportFormat string
+	calOut          string
+	calAll          bool
+	calYes          bool
+	calOnlyFuture   bool
+	calOnlyPast     bool
+	calBodyFile     string
+	calLimit        int
+	calGroupAccount bool
+	calDedupeKey    string
+	calAllDay       bool
+	calWindow       string
+	calRSVP         bool
+	calOutDir       string
+	calKeepAttend   bool
 )
 
 // calCmd represents the cal command
@@ -37,49 +69,221 @@ var calListCmd = &cobra.Command{
 	Short: "List calendar events",
 	Long:  `List calendar events from local Markdown files.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		// Parse dates
-		var fromDate, toDate time.Time
-		var err error
-
-		if calFrom != "" {
-			fromDate, err = time.Parse("2006-01-02", calFrom)
+		fromDate, toDate := listRange()
+		if calWindow != "" {
+			if calFrom != "" || calTo != "" {
+				fatal(fmt.Errorf("--window cannot be combined with --from or --to"))
+			}
+			window, err := parseWindow(calWindow)
 			if err != nil {
 				fatal(err)
 			}
-		} else {
 			fromDate = time.Now()
+			toDate = fromDate.Add(window)
+		}
+		// The default window starts now; look back instead for past events
+		if calOnlyPast && calFrom == "" && calTo == "" && calWindow == "" {
+			toDate = time.Now()
+			fromDate = toDate.AddDate(0, 0, -14)
 		}
 
-		if calTo != "" {
-			toDate, err = time.Parse("2006-01-02", calTo)
-			if err != nil {
+		opts := cal.ListOptions{
+			From:    fromDate,
+			To:      toDate,
+			Search:  calSearch,
+			Regex:   calRegex,
+			Account: calAccount,
+
+			Status:   calStatus,
+			MineOnly: calMineOnly,
+
+			Sensitivity: calSensitivity,
+
+			GroupByDay:     calGroupDay,
+			GroupByAccount: calGroupAccount,
+
+			Next:  calNext,
+			Limit: calLimit,
+
+			ShowRSVP: calRSVP,
+
+			OnlyFuture: calOnlyFuture,
+			OnlyPast:   calOnlyPast,
+
+			Format: calFormat,
+		}
+
+		if err := cal.List(cfg, opts); err != nil {
+			fatal(err)
+		}
+	},
+}
+
+// calExportCmd represents the cal export command
+var calExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export calendar events",
+	Long: `Export local calendar events as CSV with start, end, subject, location,
+account and organizer columns, or with --format ics as an iCalendar file.
+Uses the same date range as cal list.
+
+Examples:
+  md365 cal export --format csv --from 2026-03-01 --to 2026-03-31 --out march.csv
+  md365 cal export --account work --search standup > standups.csv
+  md365 cal export --format ics --output-dir backup/   # calendar-<account>.ics per account`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fromDate, toDate := listRange()
+
+		opts := cal.ListOptions{
+			From:    fromDate,
+			To:      toDate,
+			Search:  calSearch,
+			Regex:   calRegex,
+			Account: calAccount,
+		}
+
+		if calOutDir != "" {
+			if calOut != "" {
+				fatal(fmt.Errorf("--out and --output-dir cannot be combined"))
+			}
+			if err := cal.ExportDir(cfg, opts, calExportFormat, calOutDir); err != nil {
 				fatal(err)
 			}
-			// Set to end of day
-			toDate = toDate.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
-		} else {
-			toDate = time.Now().AddDate(0, 0, 14).Add(23*time.Hour + 59*time.Minute + 59*time.Second)
+			return
 		}
 
-		if err := cal.List(cfg, fromDate, toDate, calSearch, calAccount); err != nil {
+		if err := cal.Export(cfg, opts, calExportFormat, calOut); err != nil {
 			fatal(err)
 		}
 	},
 }
 
+// parseWindow parses a --window duration: Go durations like 72h or 90m,
+// plus whole days like 7d
+func parseWindow(s string) (time.Duration, error) {
+	var window time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid --window %q: %w", s, err)
+		}
+		window = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if window, err = time.ParseDuration(s); err != nil {
+			return 0, fmt.Errorf("invalid --window %q: %w", s, err)
+		}
+	}
+	if window <= 0 {
+		return 0, fmt.Errorf("--window must be positive")
+	}
+	return window, nil
+}
+
+// listRange parses --from and --to as days in the configured timezone; by
+// default from now to 14 days ahead
+func listRange() (time.Time, time.Time) {
+	var fromDate, toDate time.Time
+
+	loc, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		fatal(err)
+	}
+
+	if calFrom != "" {
+		fromDate, err = time.ParseInLocation("2006-01-02", calFrom, loc)
+		if err != nil {
+			fatal(err)
+		}
+	} else {
+		fromDate = time.Now()
+	}
+
+	if calTo != "" {
+		toDate, err = time.ParseInLocation("2006-01-02", calTo, loc)
+		if err != nil {
+			fatal(err)
+		}
+		// Set to end of day (DST-safe)
+		toDate = toDate.AddDate(0, 0, 1).Add(-time.Second)
+	} else {
+		toDate = time.Now().AddDate(0, 0, 14).Add(23*time.Hour + 59*time.Minute + 59*time.Second)
+	}
+
+	return fromDate, toDate
+}
+
 // calCreateCmd represents the cal create command
 var calCreateCmd = &cobra.Command{
 	Use:   "create",
 	Short: "Create calendar event",
-	Long:  `Create a new calendar event via Microsoft Graph API.`,
+	Long: `Create a new calendar event via Microsoft Graph API.
+
+With --file, the event is read from a markdown file with subject, start, end,
+location and attendees in the frontmatter and the description as body
+(HTML if body_type is html). Only --account, --force, --notify and --dedupe-key can be combined with --file.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		if calAccount == "" || calSubject == "" || calStart == "" || calEnd == "" {
+		if calFile != "" {
+			// The file describes the event; flags for its content would be ignored
+			for _, name := range []string{"subject", "start", "end", "location", "body", "body-file", "attendees", "recurrence", "all-day"} {
+				if cmd.Flags().Changed(name) {
+					fatal(fmt.Errorf("--%s cannot be combined with --file (the file defines the event)", name))
+				}
+			}
+
+			opts := cal.CreateOptions{Force: calForce, Notify: calNotify, DedupeKey: calDedupeKey}
+			if err := cal.CreateFromFile(cfg, calAccount, calFile, opts); err != nil {
+				fatal(err)
+			}
+			return
+		}
+
+		if calSubject == "" || calStart == "" || (calEnd == "" && !calAllDay) {
+			cmd.Help()
+			os.Exit(1)
+			return
+		}
+
+		account, err := pickAccount(calAccount)
+		if err != nil {
+			fatal(err)
+		}
+		if account == "" {
 			cmd.Help()
 			os.Exit(1)
 			return
 		}
+		calAccount = account
 
-		if err := cal.Create(cfg, calAccount, calSubject, calStart, calEnd, calLocation, calBody, calAttendees, calForce); err != nil {
+		if calBodyFile != "" {
+			if calBody != "" {
+				fatal(fmt.Errorf("--body and --body-file cannot be combined"))
+			}
+			if calBody, err = readBodyFile(calBodyFile); err != nil {
+				fatal(err)
+			}
+		}
+
+		attendees, err := resolveRecipients(calAttendees)
+		if err != nil {
+			fatal(err)
+		}
+
+		opts := cal.CreateOptions{
+			Subject:    calSubject,
+			Start:      calStart,
+			End:        calEnd,
+			Location:   calLocation,
+			Body:       calBody,
+			Attendees:  attendees,
+			Recurrence: calRecurrence,
+			Force:      calForce,
+			Notify:     calNotify,
+			DedupeKey:  calDedupeKey,
+			AllDay:     calAllDay,
+		}
+
+		if err := cal.Create(cfg, calAccount, opts); err != nil {
 			fatal(err)
 		}
 	},
@@ -89,14 +293,222 @@ var calCreateCmd = &cobra.Command{
 var calDeleteCmd = &cobra.Command{
 	Use:   "delete [file]",
 	Short: "Delete calendar event",
-	Long:  `Delete a calendar event via Microsoft Graph API.`,
+	Long: `Delete a calendar event via Microsoft Graph API.
+
+With --all, delete every local event of the account between --from and --to
+after listing them and asking for confirmation (or --yes).`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if calAll {
+			if len(args) > 0 || calID != "" {
+				fatal(fmt.Errorf("--all cannot be combined with --id or a file"))
+			}
+			deleteAllEvents()
+			return
+		}
+
 		// Check if file path is provided as argument
 		if len(args) > 0 {
 			calFile = args[0]
 		}
 
-		if err := cal.Delete(cfg, calAccount, calID, calFile); err != nil {
+		// The account of a file comes from its frontmatter
+		if calFile == "" {
+			account, err := pickAccount(calAccount)
+			if err != nil {
+				fatal(err)
+			}
+			calAccount = account
+		}
+
+		if err := cal.Delete(cfg, calAccount, calID, calFile, calNotify, calComment); err != nil {
+			fatal(err)
+		}
+	},
+}
+
+// deleteAllEvents deletes the events of one account in the --from/--to range
+func deleteAllEvents() {
+	if calFrom == "" || calTo == "" {
+		fatal(fmt.Errorf("--all requires both --from and --to"))
+	}
+
+	account, err := pickAccount(calAccount)
+	if err != nil {
+		fatal(err)
+	}
+	if account == "" {
+		fatal(fmt.Errorf("--all requires --account"))
+	}
+	if _, err := cfg.GetAccount(account); err != nil {
+		fatal(err)
+	}
+
+	fromDate, toDate := listRange()
+	events, err := cal.Matching(cfg, cal.ListOptions{From: fromDate, To: toDate, Account: account})
+	if err != nil {
+		fatal(err)
+	}
+	if len(events) == 0 {
+		fmt.Println("No events in range")
+		return
+	}
+
+	for _, event := range events {
+		fmt.Printf("%s  %s\n", event.Start.Format("2006-01-02 15:04"), event.Subject)
+	}
+
+	if !calYes {
+		if !isatty.IsTerminal(os.Stdin.Fd()) {
+			fatal(fmt.Errorf("refusing to delete %d event(s) without confirmation; pass --yes", len(events)))
+		}
+
+		confirmed := false
+		if err := huh.NewConfirm().
+			Title(fmt.Sprintf("Delete these %d event(s) from '%s'?", len(events), account)).
+			Value(&confirmed).
+			Run(); err != nil {
+			fatal(fmt.Errorf("confirmation cancelled: %w", err))
+		}
+		if !confirmed {
+			fmt.Println("Aborted")
+			return
+		}
+	}
+
+	if err := cal.DeleteEvents(cfg, events, calNotify, calComment); err != nil {
+		fatal(err)
+	}
+}
+
+// calMoveCmd represents the cal move command
+var calMoveCmd = &cobra.Command{
+	Use:   "move [file]",
+	Short: "Reschedule calendar event",
+	Long: `Move a calendar event to a new time via Microsoft Graph API.
+
+Give either --end or --duration. Times are interpreted in the configured timezone.
+Moving a meeting you organize sends its attendees an update; --notify none
+refuses such meetings instead.
+
+Examples:
+  md365 cal move --account work --id <event-id> --start "2026-03-01 14:00" --duration 30m
+  md365 cal move ~/.local/share/md365/work/calendar/2026-03-01-lunch.md --start "2026-03-02 12:00" --end "2026-03-02 13:00"`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 0 {
+			calFile = args[0]
+		}
+
+		if calStart == "" || (calEnd == "" && calDuration == 0) {
+			cmd.Help()
+			os.Exit(1)
+			return
+		}
+
+		// The account of a file comes from its frontmatter
+		if calFile == "" {
+			account, err := pickAccount(calAccount)
+			if err != nil {
+				fatal(err)
+			}
+			calAccount = account
+		}
+
+		if err := cal.Move(cfg, calAccount, calID, calFile, calStart, calEnd, calDuration, calNotify); err != nil {
+			fatal(err)
+		}
+	},
+}
+
+// calImportCmd represents the cal import command
+var calImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import events from an .ics file",
+	Long: `Create an event via Microsoft Graph API for each VEVENT in an .ics file.
+
+Times are converted to the configured timezone. Attendees are checked against
+the cross-tenant guard and receive an invitation. For invites organized by
+someone else (ORGANIZER is not this account), attendees are dropped unless
+--keep-attendees is given. Re-importing the same file does not create
+duplicates.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if calFile == "" {
+			cmd.Help()
+			os.Exit(1)
+			return
+		}
+
+		account, err := pickAccount(calAccount)
+		if err != nil {
+			fatal(err)
+		}
+		if account == "" {
+			cmd.Help()
+			os.Exit(1)
+			return
+		}
+
+		if err := cal.Import(cfg, account, calFile, calForce, calNotify, calKeepAttend); err != nil {
+			fatal(err)
+		}
+	},
+}
+
+// calFreeBusyCmd represents the cal freebusy command
+var calFreeBusyCmd = &cobra.Command{
+	Use:   "freebusy",
+	Short: "Show attendee free/busy",
+	Long: `Show the busy blocks of one or more people via Microsoft Graph API.
+
+Defaults to the next 7 days. Times are shown in the configured timezone.
+
+Example:
+  md365 cal freebusy --account work --attendees jane@company.com,joe@company.com --from 2026-03-02 --to 2026-03-06`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(calAttendees) == 0 {
+			cmd.Help()
+			os.Exit(1)
+			return
+		}
+
+		account, err := pickAccount(calAccount)
+		if err != nil {
+			fatal(err)
+		}
+		if account == "" {
+			cmd.Help()
+			os.Exit(1)
+			return
+		}
+
+		loc, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			fatal(err)
+		}
+
+		fromDate := time.Now().In(loc)
+		if calFrom != "" {
+			fromDate, err = time.ParseInLocation("2006-01-02", calFrom, loc)
+			if err != nil {
+				fatal(err)
+			}
+		}
+
+		toDate := fromDate.AddDate(0, 0, 7)
+		if calTo != "" {
+			toDate, err = time.ParseInLocation("2006-01-02", calTo, loc)
+			if err != nil {
+				fatal(err)
+			}
+			// Include the whole end day
+			toDate = toDate.AddDate(0, 0, 1)
+		}
+
+		attendees, err := resolveRecipients(calAttendees)
+		if err != nil {
+			fatal(err)
+		}
+
+		if err := cal.FreeBusy(cfg, account, attendees, fromDate, toDate, calInterval); err != nil {
 			fatal(err)
 		}
 	},
@@ -106,24 +518,85 @@ func init() {
 	// cal list
 	calListCmd.Flags().StringVar(&calFrom, "from", "", "Start date (YYYY-MM-DD)")
 	calListCmd.Flags().StringVar(&calTo, "to", "", "End date (YYYY-MM-DD)")
+	calListCmd.Flags().StringVar(&calWindow, "window", "", "Events from now until now + window, e.g. 72h or 7d (instead of --from/--to)")
 	calListCmd.Flags().StringVar(&calSearch, "search", "", "Search query")
-	calListCmd.Flags().StringVar(&calAccount, "account", "", "Filter by account")
+	calListCmd.Flags().BoolVar(&calRegex, "regex", false, "Treat --search as a case-insensitive regular expression")
+	calListCmd.Flags().BoolVar(&calGroupDay, "group-by-day", false, "Group events under a header per day")
+	calListCmd.Flags().BoolVar(&calGroupAccount, "group-by-account", false, "Section events under a header per account")
+	calListCmd.Flags().StringVar(&calStatus, "status", "all", "Filter by response: accepted, tentative, declined, none, all")
+	calListCmd.Flags().BoolVar(&calMineOnly, "mine-only", false, "Hide declined events")
+	calListCmd.Flags().StringVar(&calFormat, "format", "", `Go template per event, e.g. '{{.Start.Format "15:04"}} {{.Subject}}' (fields: ID, Start, End, AllDay, Subject, Location, Response, Sensitivity, Organizer, Attendees, RSVP, Account, FilePath), or ics for an iCalendar feed`)
+	calListCmd.Flags().StringVar(&calSensitivity, "sensitivity", "all", "Filter by sensitivity: normal, personal, private, confidential, all")
+	calListCmd.Flags().StringVar(&calAccount, "account", "", "Filter by account (all or empty for every account)")
+	calListCmd.Flags().IntVar(&calNext, "next", 0, "Show only the next N upcoming events (ignores --to)")
+	calListCmd.Flags().IntVar(&calLimit, "limit", 0, "Show at most N events (after sorting)")
+	calListCmd.Flags().BoolVar(&calRSVP, "rsvp", false, "Show attendee count and responses below each meeting")
+	calListCmd.Flags().BoolVar(&calOnlyFuture, "only-future", false, "Only events that have not started yet")
+	calListCmd.Flags().BoolVar(&calOnlyPast, "only-past", false, "Only events that have ended (default window: the last 14 days)")
+
+	// cal export
+	calExportCmd.Flags().StringVar(&calAccount, "account", "", "Filter by account")
+	calExportCmd.Flags().StringVar(&calFrom, "from", "", "Start date (YYYY-MM-DD, default today)")
+	calExportCmd.Flags().StringVar(&calTo, "to", "", "End date (YYYY-MM-DD, default +14 days)")
+	calExportCmd.Flags().StringVar(&calSearch, "search", "", "Search query")
+	calExportCmd.Flags().BoolVar(&calRegex, "regex", false, "Treat --search as a case-insensitive regular expression")
+	calExportCmd.Flags().StringVar(&calExportFormat, "format", "csv", "Export format: csv or ics")
+	calExportCmd.Flags().StringVar(&calOut, "out", "", "Output file (default: stdout)")
+	calExportCmd.Flags().StringVar(&calOutDir, "output-dir", "", "Write one calendar-<account> file per account into this directory")
 
 	// cal create
-	calCreateCmd.Flags().StringVar(&calAccount, "account", "", "Account (required)")
+	calCreateCmd.Flags().StringVar(&calAccount, "account", "", accountFlagHelp)
 	calCreateCmd.Flags().StringVar(&calSubject, "subject", "", "Event subject (required)")
 	calCreateCmd.Flags().StringVar(&calStart, "start", "", "Start date/time (required)")
-	calCreateCmd.Flags().StringVar(&calEnd, "end", "", "End date/time (required)")
+	calCreateCmd.Flags().StringVar(&calEnd, "end", "", "End date/time (required unless --all-day)")
 	calCreateCmd.Flags().StringVar(&calLocation, "location", "", "Location")
 	calCreateCmd.Flags().StringVar(&calBody, "body", "", "Body text")
-	calCreateCmd.Flags().StringSliceVar(&calAttendees, "attendees", []string{}, "Attendee emails (comma-separated)")
+	calCreateCmd.Flags().StringVar(&calBodyFile, "body-file", "", "Read the body text from a file (- for stdin)")
+	calCreateCmd.Flags().StringSliceVar(&calAttendees, "attendees", []string{}, "Attendee emails or @Name of synced contacts (comma-separated)")
+	calCreateCmd.Flags().StringVar(&calRecurrence, "recurrence", "", "Repeat the event, e.g. weekly:MO,WE;count=10 or daily;until=2026-12-31")
 	calCreateCmd.Flags().BoolVar(&calForce, "force", false, "Bypass cross-tenant checks")
+	calCreateCmd.Flags().BoolVar(&calAllDay, "all-day", false, "All-day event: --start and --end are dates, --end exclusive and optional (one day)")
+	calCreateCmd.Flags().StringVar(&calDedupeKey, "dedupe-key", "", "Idempotency key: repeating a create with the same key does not create a second event")
+	calCreateCmd.Flags().StringVar(&calFile, "file", "", "Create from a markdown file instead of flags")
+	calCreateCmd.Flags().StringVar(&calNotify, "notify", cal.NotifyAll, "Attendee notifications: all, or none to refuse sending invitations")
 
 	// cal delete
-	calDeleteCmd.Flags().StringVar(&calAccount, "account", "", "Account")
+	calDeleteCmd.Flags().StringVar(&calAccount, "account", "", accountFlagHelp)
 	calDeleteCmd.Flags().StringVar(&calID, "id", "", "Event ID")
+	calDeleteCmd.Flags().StringVar(&calNotify, "notify", cal.NotifyAll, "Attendee notifications: all, or none to refuse cancelling meetings you organize")
+	calDeleteCmd.Flags().StringVar(&calComment, "comment", "", "Message sent with the cancellation of a meeting you organize")
+	calDeleteCmd.Flags().BoolVar(&calAll, "all", false, "Delete all events of the account between --from and --to")
+	calDeleteCmd.Flags().StringVar(&calFrom, "from", "", "Start date for --all (YYYY-MM-DD)")
+	calDeleteCmd.Flags().StringVar(&calTo, "to", "", "End date for --all (YYYY-MM-DD)")
+	calDeleteCmd.Flags().BoolVarP(&calYes, "yes", "y", false, "Delete without asking for confirmation (with --all)")
+
+	// cal import
+	calImportCmd.Flags().StringVar(&calAccount, "account", "", accountFlagHelp)
+	calImportCmd.Flags().StringVar(&calFile, "file", "", "The .ics file to import (required)")
+	calImportCmd.Flags().BoolVar(&calForce, "force", false, "Bypass cross-tenant checks")
+	calImportCmd.Flags().StringVar(&calNotify, "notify", cal.NotifyAll, "Attendee notifications: all, or none to refuse sending invitations")
+	calImportCmd.Flags().BoolVar(&calKeepAttend, "keep-attendees", false, "Invite the attendees of invites organized by someone else")
+
+	// cal move
+	calMoveCmd.Flags().StringVar(&calAccount, "account", "", accountFlagHelp)
+	calMoveCmd.Flags().StringVar(&calID, "id", "", "Event ID")
+	calMoveCmd.Flags().StringVar(&calStart, "start", "", "New start date/time (required)")
+	calMoveCmd.Flags().StringVar(&calEnd, "end", "", "New end date/time")
+	calMoveCmd.Flags().DurationVar(&calDuration, "duration", 0, "New duration (e.g. 30m, 1h30m), instead of --end")
+	calMoveCmd.Flags().StringVar(&calNotify, "notify", cal.NotifyAll, "Attendee notifications: all, or none to refuse moving meetings you organize")
+
+	// cal freebusy
+	calFreeBusyCmd.Flags().StringVar(&calAccount, "account", "", "Account to query with (default: default_account)")
+	calFreeBusyCmd.Flags().StringSliceVar(&calAttendees, "attendees", []string{}, "Emails or @Name of synced contacts to look up (comma-separated, required)")
+	calFreeBusyCmd.Flags().StringVar(&calFrom, "from", "", "Start date (YYYY-MM-DD, default now)")
+	calFreeBusyCmd.Flags().StringVar(&calTo, "to", "", "End date (YYYY-MM-DD, default +7 days)")
+	calFreeBusyCmd.Flags().IntVar(&calInterval, "interval", 30, "Availability interval in minutes")
 
 	calCmd.AddCommand(calListCmd)
+	calCmd.AddCommand(calExportCmd)
 	calCmd.AddCommand(calCreateCmd)
 	calCmd.AddCommand(calDeleteCmd)
+	calCmd.AddCommand(calMoveCmd)
+	calCmd.AddCommand(calImportCmd)
+	calCmd.AddCommand(calFreeBusyCmd)
 }
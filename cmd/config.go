@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"github.com/lcorneliussen/md365/internal/config"
+	"github.com/lcorneliussen/md365/internal/output"
+	"github.com/spf13/cobra"
+)
+
+var (
+	configShowJSON bool
+)
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Configuration commands",
+	Long:  `Inspect the md365 configuration.`,
+}
+
+// configShowCmd represents the config show command
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show effective configuration",
+	Long: `Print the fully-resolved configuration, including defaults, environment
+overrides, and each account's effective client id and auth flow.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := config.Show(cfg, configShowJSON || output.JSON()); err != nil {
+			fatal(err)
+		}
+	},
+}
+
+// configSetDefaultCmd represents the config set-default command
+var configSetDefaultCmd = &cobra.Command{
+	Use:   "set-default ACCOUNT",
+	Short: "Set the default account",
+	Long: `Store ACCOUNT as default_account in the config file.
+
+Commands acting on a single account use it when --account is omitted:
+auth login/refresh/scopes/whoami, cal create/delete/move/import/freebusy
+and mail send. Listing, searching and sync still cover all accounts.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := config.SetDefaultAccount(args[0]); err != nil {
+			fatal(err)
+		}
+		output.Infof("Default account set to '%s'\n", args[0])
+	},
+}
+
+func init() {
+	configShowCmd.Flags().BoolVar(&configShowJSON, "json", false, "Output as JSON")
+
+	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configSetDefaultCmd)
+}
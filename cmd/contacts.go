@@ -1,12 +1,24 @@
 package cmd
 
 import (
+	"fmt"
+
 	"github.com/lcorneliussen/md365/internal/contacts"
+	"github.com/lcorneliussen/md365/internal/output"
 	"github.com/spf13/cobra"
 )
 
 var (
 	contactsAccount string
+	contactsRegex   bool
+	contactsDedupBy string
+	contactsID      string
+	contactsJSON    bool
+	contactsFormat  string
+	contactsLimit   int
+	contactsOut     string
+	contactsExpFmt  string
+	contactsOutDir  string
 )
 
 // contactsCmd represents the contacts command
@@ -23,9 +35,81 @@ var contactsSearchCmd = &cobra.Command{
 	Long:  `Search for contacts matching a query.`,
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		query := args[0]
+		opts := contacts.SearchOptions{
+			Query:   args[0],
+			Regex:   contactsRegex,
+			Account: contactsAccount,
+			Format:  contactsFormat,
+			Limit:   contactsLimit,
+		}
+
+		if err := contacts.Search(cfg, opts); err != nil {
+			fatal(err)
+		}
+	},
+}
 
-		if err := contacts.Search(cfg, query, contactsAccount); err != nil {
+// contactsDedupeCmd represents the contacts dedupe command
+var contactsDedupeCmd = &cobra.Command{
+	Use:   "dedupe",
+	Short: "Report suspected duplicate contacts",
+	Long:  `Find contacts sharing an email address or a normalized name and print them as clusters. Nothing is modified.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := contacts.Dedupe(cfg, contactsAccount, contactsDedupBy); err != nil {
+			fatal(err)
+		}
+	},
+}
+
+// contactsShowCmd represents the contacts show command
+var contactsShowCmd = &cobra.Command{
+	Use:   "show [file]",
+	Short: "Show contact details",
+	Long: `Print all details of a contact, given as a file or by --id.
+
+Examples:
+  md365 contacts show ~/.local/share/md365/work/contacts/jane-doe.md
+  md365 contacts show --id <contact-id> --json`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var file string
+		if len(args) > 0 {
+			file = args[0]
+		}
+
+		if contactsJSON {
+			output.SetFormat(output.FormatJSON)
+		}
+
+		if err := contacts.Show(cfg, contactsAccount, contactsID, file); err != nil {
+			fatal(err)
+		}
+	},
+}
+
+// contactsExportCmd represents the contacts export command
+var contactsExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export contacts",
+	Long: `Export local contacts as CSV with one row per contact. Multiple emails and
+phones are joined with "; ".
+
+Examples:
+  md365 contacts export --format csv --out contacts.csv
+  md365 contacts export --account work > work.csv
+  md365 contacts export --output-dir backup/   # contacts-<account>.csv per account`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if contactsOutDir != "" {
+			if contactsOut != "" {
+				fatal(fmt.Errorf("--out and --output-dir cannot be combined"))
+			}
+			if err := contacts.ExportDir(cfg, contactsAccount, contactsExpFmt, contactsOutDir); err != nil {
+				fatal(err)
+			}
+			return
+		}
+
+		if err := contacts.Export(cfg, contactsAccount, contactsExpFmt, contactsOut); err != nil {
 			fatal(err)
 		}
 	},
@@ -33,6 +117,24 @@ var contactsSearchCmd = &cobra.Command{
 
 func init() {
 	contactsSearchCmd.Flags().StringVar(&contactsAccount, "account", "", "Filter by account")
+	contactsSearchCmd.Flags().StringVar(&contactsFormat, "format", "", `Go template per contact, e.g. 'alias {{.ID}} {{.DisplayName}} <{{index .Emails 0}}>' (fields: DisplayName, GivenName, Surname, Emails, Phones, Company, JobTitle, Birthday, Account, ID, FilePath; join)`)
+	contactsSearchCmd.Flags().IntVar(&contactsLimit, "limit", 0, "Show at most N contacts")
+	contactsSearchCmd.Flags().BoolVar(&contactsRegex, "regex", false, "Treat QUERY as a case-insensitive regular expression")
+
+	contactsDedupeCmd.Flags().StringVar(&contactsAccount, "account", "", "Filter by account")
+	contactsDedupeCmd.Flags().StringVar(&contactsDedupBy, "by", contacts.MatchEmail, "Match strategy: email, name or both")
+
+	contactsShowCmd.Flags().StringVar(&contactsAccount, "account", "", "Account to look up --id in (default: all)")
+	contactsShowCmd.Flags().StringVar(&contactsID, "id", "", "Contact ID")
+	contactsShowCmd.Flags().BoolVar(&contactsJSON, "json", false, "Print as JSON (same as --output json)")
 
 	contactsCmd.AddCommand(contactsSearchCmd)
+	contactsExportCmd.Flags().StringVar(&contactsAccount, "account", "", "Filter by account")
+	contactsExportCmd.Flags().StringVar(&contactsExpFmt, "format", "csv", "Export format (csv)")
+	contactsExportCmd.Flags().StringVar(&contactsOut, "out", "", "Output file (default: stdout)")
+	contactsExportCmd.Flags().StringVar(&contactsOutDir, "output-dir", "", "Write one contacts-<account> file per account into this directory")
+
+	contactsCmd.AddCommand(contactsShowCmd)
+	contactsCmd.AddCommand(contactsExportCmd)
+	contactsCmd.AddCommand(contactsDedupeCmd)
 }
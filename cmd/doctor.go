@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/lcorneliussen/md365/internal/auth"
+	"github.com/lcorneliussen/md365/internal/config"
+	"github.com/lcorneliussen/md365/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the setup end-to-end",
+	Long: `Check that the config parses, the system keyring works, and that every
+account has a token that refreshes and can call the Graph API.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !runDoctor() {
+			os.Exit(1)
+		}
+	},
+}
+
+// runDoctor prints a checklist and reports whether all checks passed
+func runDoctor() bool {
+	ok := true
+	check := func(name string, err error, fix string) bool {
+		if err == nil {
+			fmt.Printf("[%s] %s\n", output.Green(" OK "), name)
+			return true
+		}
+		fmt.Printf("[%s] %s: %v\n", output.Red("FAIL"), name, err)
+		if fix != "" {
+			fmt.Printf("       Fix: %s\n", fix)
+		}
+		ok = false
+		return false
+	}
+	warn := func(name string, err error, note string) {
+		fmt.Printf("[%s] %s: %v\n", output.Yellow("WARN"), name, err)
+		if note != "" {
+			fmt.Printf("       %s\n", note)
+		}
+	}
+
+	// Config
+	var err error
+	cfg, err = loadConfig()
+	if !check("Config "+config.GetConfigPath(), err, "create or correct the config file (see config.example.yaml)") {
+		return false
+	}
+	if len(cfg.Accounts) == 0 {
+		check("Accounts configured", fmt.Errorf("none"), "md365 auth add -i")
+	}
+
+	// Keyring
+	if err := auth.CheckKeyring(); err != nil {
+		warn("Keyring", err, "Tokens fall back to files in the config directory. Start a keyring daemon (e.g. gnome-keyring) to store them securely.")
+	} else {
+		check("Keyring", nil, "")
+	}
+
+	// Clock
+	if skew, err := auth.ClockSkew(); err != nil {
+		check("Network (login.microsoftonline.com)", err, "check your internet connection and proxy settings")
+	} else {
+		check("Network (login.microsoftonline.com)", nil, "")
+		if skew > auth.MaxClockSkew || skew < -auth.MaxClockSkew {
+			warn("Clock", fmt.Errorf("off by %s", skew), "Sync your system clock; tokens may look expired or not yet valid.")
+		}
+	}
+
+	// Accounts
+	accounts := cfg.ListAccounts()
+	sort.Strings(accounts)
+	for _, account := range accounts {
+		login := fmt.Sprintf("md365 auth login --account %s", account)
+
+		if !check(fmt.Sprintf("Account '%s': token refresh", account), auth.RefreshToken(cfg, account), login) {
+			continue
+		}
+
+		meFix := login + " --add-scope User.Read"
+		if cfg.GetAuthFlow(account) == auth.FlowClientCredentials {
+			meFix = "grant the app registration the User.Read.All application permission"
+		}
+		user, err := auth.GetMe(cfg, account)
+		if !check(fmt.Sprintf("Account '%s': Graph /me", account), err, meFix) {
+			continue
+		}
+		fmt.Printf("       Signed in as %s\n", user.UserPrincipalName)
+	}
+
+	fmt.Println()
+	if ok {
+		fmt.Println("All checks passed")
+	} else {
+		fmt.Println("Some checks failed")
+	}
+	return ok
+}
@@ -1,17 +1,23 @@
 package cmd
 
 import (
+	"fmt"
+
+	"github.com/lcorneliussen/md365/internal/contacts"
 	"github.com/lcorneliussen/md365/internal/mail"
 	"os"
 	"github.com/spf13/cobra"
 )
 
 var (
-	mailAccount string
-	mailTo      string
-	mailSubject string
-	mailBody    string
-	mailForce   bool
+	mailAccount  string
+	mailTo       string
+	mailSubject  string
+	mailBody     string
+	mailBodyFile string
+	mailForce    bool
+	mailDryRun   bool
+	mailFrom     string
 )
 
 // mailCmd represents the mail command
@@ -27,24 +33,45 @@ var mailSendCmd = &cobra.Command{
 	Short: "Send email",
 	Long:  `Send an email via Microsoft Graph API.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		if mailAccount == "" || mailTo == "" || mailSubject == "" {
+		account, err := pickAccount(mailAccount)
+		if err != nil {
+			fatal(err)
+		}
+		if account == "" || mailTo == "" || mailSubject == "" {
 			cmd.Help()
 			os.Exit(1)
 			return
 		}
+		mailAccount = account
+
+		if mailBodyFile != "" {
+			if mailBody != "" {
+				fatal(fmt.Errorf("--body and --body-file cannot be combined"))
+			}
+			if mailBody, err = readBodyFile(mailBodyFile); err != nil {
+				fatal(err)
+			}
+		}
+
+		if mailTo, err = contacts.Resolve(cfg, mailTo); err != nil {
+			fatal(err)
+		}
 
-		if err := mail.Send(cfg, mailAccount, mailTo, mailSubject, mailBody, mailForce); err != nil {
+		if err := mail.Send(cfg, mailAccount, mailFrom, mailTo, mailSubject, mailBody, mailForce, mailDryRun); err != nil {
 			fatal(err)
 		}
 	},
 }
 
 func init() {
-	mailSendCmd.Flags().StringVar(&mailAccount, "account", "", "Account (required)")
-	mailSendCmd.Flags().StringVar(&mailTo, "to", "", "Recipient email (required)")
+	mailSendCmd.Flags().StringVar(&mailAccount, "account", "", accountFlagHelp)
+	mailSendCmd.Flags().StringVar(&mailTo, "to", "", "Recipient email, or @Name of a synced contact (required)")
+	mailSendCmd.Flags().StringVar(&mailFrom, "from", "", "Send as this shared or delegated mailbox instead of your own")
 	mailSendCmd.Flags().StringVar(&mailSubject, "subject", "", "Email subject (required)")
 	mailSendCmd.Flags().StringVar(&mailBody, "body", "", "Email body")
+	mailSendCmd.Flags().StringVar(&mailBodyFile, "body-file", "", "Read the email body from a file (- for stdin)")
 	mailSendCmd.Flags().BoolVar(&mailForce, "force", false, "Bypass cross-tenant checks")
+	mailSendCmd.Flags().BoolVar(&mailDryRun, "dry-run", false, "Print the message instead of sending it")
 
 	mailCmd.AddCommand(mailSendCmd)
 }
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/lcorneliussen/md365/internal/output"
+	"github.com/lcorneliussen/md365/internal/sync"
+	"github.com/spf13/cobra"
+)
+
+var (
+	migrateAccount string
+	migrateDryRun  bool
+)
+
+// migrateCmd represents the migrate command
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade local files to the current frontmatter schema",
+	Long: fmt.Sprintf(`Rewrite the frontmatter of synced event and contact files to the current
+schema (version %d) in place. Bodies are left untouched and each changed file
+is backed up to <file>.bak first. Files not written by md365 are skipped.`, sync.SchemaVersion),
+	Run: func(cmd *cobra.Command, args []string) {
+		accounts := cfg.ListAccounts()
+		if migrateAccount != "" {
+			if _, err := cfg.GetAccount(migrateAccount); err != nil {
+				fatal(err)
+			}
+			accounts = []string{migrateAccount}
+		}
+
+		result, err := sync.Migrate(cfg, accounts, migrateDryRun)
+		if err != nil {
+			fatal(err)
+		}
+
+		if output.JSON() {
+			if err := output.PrintJSON(result); err != nil {
+				fatal(err)
+			}
+			return
+		}
+
+		verb := "Migrated"
+		if migrateDryRun {
+			verb = "Would migrate"
+		}
+		fmt.Printf("%s %d file(s); %d already current, %d skipped\n", verb, result.Migrated, result.Current, result.Skipped)
+	},
+}
+
+func init() {
+	migrateCmd.Flags().StringVar(&migrateAccount, "account", "", "Only migrate this account (default: all)")
+	migrateCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "Only report which files would change")
+}
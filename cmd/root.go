@@ -1,16 +1,39 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"sort"
+	"syscall"
 
+	"github.com/charmbracelet/huh"
+	"github.com/lcorneliussen/md365/internal/auth"
 	"github.com/lcorneliussen/md365/internal/config"
+	"github.com/lcorneliussen/md365/internal/contacts"
+	"github.com/lcorneliussen/md365/internal/graph"
+	"github.com/lcorneliussen/md365/internal/output"
+	"github.com/mattn/go-isatty"
 	"github.com/spf13/cobra"
 )
 
 var (
 	cfg         *config.Config
 	Interactive bool
+	configPath  string
+	profileName string
+	dataDirPath string
+	outputFmt   string
+	verbose     bool
+	debug       bool
+	beta        bool
+	quiet       bool
+	colorMode   string
+	reconsent   bool
+	insecure    bool
+	dumpPath    string
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -22,28 +45,107 @@ var rootCmd = &cobra.Command{
 Syncs calendars and contacts as plain Markdown files with YAML frontmatter.
 Write operations go through Microsoft Graph API.`,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-		// Skip config loading for commands that don't need it
-		if cmd.Name() == "help" || cmd.Name() == "md365" || cmd.Name() == "add" {
+		if err := output.SetFormat(outputFmt); err != nil {
+			return err
+		}
+		if err := output.SetColor(colorMode); err != nil {
+			return err
+		}
+
+		graph.SetVerbose(verbose, debug)
+		if insecure {
+			graph.SetInsecureSkipVerify()
+		}
+		if dumpPath != "" {
+			if err := graph.SetDumpDir(dumpPath); err != nil {
+				return err
+			}
+		}
+		output.SetQuiet(quiet)
+		auth.Reconsent = reconsent
+
+		// Namespace config, data and tokens under the profile
+		if profileName != "" {
+			if err := config.SetProfile(profileName); err != nil {
+				return err
+			}
+		}
+
+		// Point config loading/saving at an alternate file
+		if configPath != "" {
+			config.SetConfigPath(configPath)
+		}
+
+		// Skip config loading for commands that don't need it; doctor
+		// loads it itself to report errors
+		if cmd.Name() == "help" || cmd.Name() == "md365" || cmd.Name() == "add" || cmd.Name() == "doctor" {
 			return nil
 		}
 
 		var err error
-		cfg, err = config.Load()
-		if err != nil {
-			return err
-		}
-		return nil
+		cfg, err = loadConfig()
+		return err
 	},
 }
 
+// loadConfig loads the config file and applies the global flag overrides
+func loadConfig() (*config.Config, error) {
+	c, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	// --data-dir takes precedence over MD365_DATA_DIR and data_dir
+	if dataDirPath != "" {
+		c.SetDataDir(dataDirPath)
+	}
+
+	// --beta overrides graph_version for this invocation
+	if beta {
+		c.GraphVersion = "beta"
+	}
+	return c, nil
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
+// The command context is cancelled on the first SIGINT/SIGTERM; a second
+// signal terminates immediately.
 func Execute() error {
-	return rootCmd.Execute()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	go func() {
+		<-signals
+		fmt.Fprintln(os.Stderr, "interrupted, stopping after current item")
+		cancel()
+		// Restore default handling so a second signal terminates
+		signal.Stop(signals)
+	}()
+
+	return rootCmd.ExecuteContext(ctx)
 }
 
 func init() {
 	// Global flags
 	rootCmd.PersistentFlags().BoolVarP(&Interactive, "interactive", "i", false, "Use interactive TUI mode")
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Config file (default $XDG_CONFIG_HOME/md365/config.yaml)")
+	rootCmd.PersistentFlags().StringVar(&profileName, "profile", "", "Isolated profile: separate config, data and tokens under profiles/<name>")
+	rootCmd.PersistentFlags().StringVar(&dataDirPath, "data-dir", "", "Data directory (overrides MD365_DATA_DIR and data_dir)")
+	rootCmd.PersistentFlags().StringVarP(&outputFmt, "output", "o", output.FormatText, "Output format: text or json")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Log Graph API requests to stderr")
+	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "Log Graph API requests with headers and response bodies")
+	rootCmd.PersistentFlags().StringVar(&colorMode, "color", output.ColorAuto, "Color output: auto (terminal only, honors NO_COLOR), always or never")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Only print errors, warnings and requested output")
+	rootCmd.PersistentFlags().BoolVar(&insecure, "insecure-skip-verify", false, "Skip TLS certificate verification (testing against mock servers only)")
+	rootCmd.PersistentFlags().MarkHidden("insecure-skip-verify")
+	rootCmd.PersistentFlags().StringVar(&dumpPath, "dump", "", "Write every raw Graph response body to a timestamped file in this directory")
+	rootCmd.PersistentFlags().MarkHidden("dump")
+	rootCmd.PersistentFlags().BoolVar(&reconsent, "reconsent", false, "Sign in again if the config requests scopes the token lacks")
+	rootCmd.PersistentFlags().BoolVar(&beta, "beta", false, "Use the Graph beta endpoint (overrides graph_version)")
 
 	// Add subcommands
 	rootCmd.AddCommand(syncCmd)
@@ -51,6 +153,9 @@ func init() {
 	rootCmd.AddCommand(contactsCmd)
 	rootCmd.AddCommand(mailCmd)
 	rootCmd.AddCommand(authCmd)
+	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(doctorCmd)
+	rootCmd.AddCommand(migrateCmd)
 }
 
 // fatal prints an error and exits
@@ -58,3 +163,71 @@ func fatal(err error) {
 	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 	os.Exit(1)
 }
+
+// accountFlagHelp is the --account usage of commands that honor default_account
+const accountFlagHelp = "Account (default: default_account from config)"
+
+// pickAccount returns account unchanged if set, falling back to the
+// configured default_account; otherwise, on a terminal, it prompts for one
+// of the configured accounts. Returns "" if none was chosen.
+func pickAccount(account string) (string, error) {
+	if account != "" {
+		return account, nil
+	}
+	if cfg.DefaultAccount != "" {
+		return cfg.DefaultAccount, nil
+	}
+	if !isatty.IsTerminal(os.Stdin.Fd()) {
+		return "", nil
+	}
+
+	accounts := cfg.ListAccounts()
+	if len(accounts) == 0 {
+		return "", nil
+	}
+	sort.Strings(accounts)
+
+	options := make([]huh.Option[string], len(accounts))
+	for i, name := range accounts {
+		options[i] = huh.NewOption(name, name)
+	}
+
+	if err := huh.NewSelect[string]().
+		Title("Select account").
+		Options(options...).
+		Value(&account).
+		Run(); err != nil {
+		return "", fmt.Errorf("account selection cancelled: %w", err)
+	}
+
+	return account, nil
+}
+
+// resolveRecipients replaces "@Name" entries with the email of the matching
+// synced contact
+func resolveRecipients(recipients []string) ([]string, error) {
+	resolved := make([]string, len(recipients))
+	for i, r := range recipients {
+		email, err := contacts.Resolve(cfg, r)
+		if err != nil {
+			return nil, err
+		}
+		resolved[i] = email
+	}
+	return resolved, nil
+}
+
+// readBodyFile reads a message body from path, or from stdin if path is "-"
+func readBodyFile(path string) (string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read body file: %w", err)
+	}
+	return string(data), nil
+}
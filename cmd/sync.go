@@ -1,54 +1,295 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/lcorneliussen/md365/internal/auth"
+	"github.com/lcorneliussen/md365/internal/output"
 	"github.com/lcorneliussen/md365/internal/sync"
 	"github.com/spf13/cobra"
 )
 
 var (
-	syncAccount string
+	syncAccount    string
+	syncAccounts   []string
+	syncForce      bool
+	syncAllowEmpty bool
+	syncSince      string
+	syncSinceLast  bool
+	syncNoPrune    bool
+	syncReport     string
+	syncReportFile string
+	syncWatch      bool
+	syncInterval   time.Duration
+	syncFull       bool
+	syncVerify     bool
 )
 
+// maxWatchBackoff caps how many intervals --watch waits after repeated failures
+const maxWatchBackoff = 8
+
 // syncCmd represents the sync command
 var syncCmd = &cobra.Command{
 	Use:   "sync [all]",
 	Short: "Sync calendars and contacts",
-	Long:  `Sync calendars and contacts from Microsoft 365 to local Markdown files.`,
+	Long: `Sync calendars and contacts from Microsoft 365 to local Markdown files.
+
+Exits with status 1 if the calendar or contacts sync of any account failed;
+the remaining accounts are still synced. With --verify, the synced files are
+then checked for duplicate ids, broken frontmatter and invalid dates, and
+any problem also exits with status 1.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		// Determine which accounts to sync
 		var accounts []string
 
-		if syncAccount == "all" || syncAccount == "" {
-			accounts = cfg.ListAccounts()
+		if len(syncAccounts) > 0 && syncAccount != "" {
+			fatal(fmt.Errorf("--account and --accounts cannot be combined"))
+		}
+
+		if len(syncAccounts) > 0 {
+			// Explicitly listed accounts sync even if disabled
+			for _, account := range syncAccounts {
+				account = strings.TrimSpace(account)
+				if _, err := cfg.GetAccount(account); err != nil {
+					fatal(err)
+				}
+				accounts = append(accounts, account)
+			}
+		} else if syncAccount == "all" || syncAccount == "" {
+			// Disabled accounts only sync when named explicitly
+			for _, account := range cfg.ListAccounts() {
+				if cfg.AccountEnabled(account) {
+					accounts = append(accounts, account)
+				} else {
+					output.Progressf("Skipping disabled account '%s'\n", account)
+				}
+			}
 		} else {
 			accounts = []string{syncAccount}
 		}
 
-		// Sync each account
-		for _, account := range accounts {
-			// Get access token
-			token, err := auth.GetAccessToken(cfg, account)
+		if syncWatch && syncInterval <= 0 {
+			fatal(fmt.Errorf("--interval must be positive"))
+		}
+
+		if syncReportFile != "" && syncReport == "" {
+			syncReport = "json"
+		}
+		if syncReport != "" && syncReport != "json" {
+			fatal(fmt.Errorf("invalid --report format %q (only json is supported)", syncReport))
+		}
+
+		opts := sync.Options{
+			Force:      syncForce,
+			AllowEmpty: syncAllowEmpty,
+			NoPrune:    syncNoPrune || !cfg.PruneEnabled(),
+			Quiet:      syncReport != "" && syncReportFile == "",
+		}
+
+		if syncFull && syncSinceLast {
+			fatal(fmt.Errorf("--full and --since-last-sync cannot be combined"))
+		}
+		if syncSince != "" && syncSinceLast {
+			fatal(fmt.Errorf("--since and --since-last-sync cannot be combined"))
+		}
+		opts.SinceLast = syncSinceLast
+		opts.Full = syncFull
+
+		if syncSince != "" {
+			loc, err := time.LoadLocation(cfg.Timezone)
 			if err != nil {
-				fmt.Fprintf(cmd.ErrOrStderr(), "Failed to sync '%s': %v\n", account, err)
-				continue
+				fatal(err)
 			}
+			opts.Since, err = time.ParseInLocation("2006-01-02", syncSince, loc)
+			if err != nil {
+				fatal(fmt.Errorf("invalid --since date: %w", err))
+			}
+		}
 
-			// Sync calendar
-			if err := sync.SyncCalendar(cfg, account, token); err != nil {
-				fmt.Fprintf(cmd.ErrOrStderr(), "Failed to sync calendar for '%s': %v\n", account, err)
+		// Reports go to stdout unless written to a file. The file is closed
+		// explicitly: the exit paths below skip deferred calls.
+		report := os.Stdout
+		closeReport := func() {}
+		if syncReportFile != "" {
+			f, err := os.Create(syncReportFile)
+			if err != nil {
+				fatal(fmt.Errorf("failed to create report file: %w", err))
+			}
+			report = f
+			closeReport = func() {
+				if err := f.Close(); err != nil {
+					fatal(fmt.Errorf("failed to write report file: %w", err))
+				}
 			}
+		}
+
+		ctx := cmd.Context()
+
+		cycle := func() bool {
+			ok := true
+			for _, account := range accounts {
+				if ctx.Err() != nil {
+					return false
+				}
+
+				rep := syncOneAccount(cmd, account, opts)
+				if len(rep.Errors) > 0 {
+					ok = false
+				}
 
-			// Sync contacts
-			if err := sync.SyncContacts(cfg, account, token); err != nil {
-				fmt.Fprintf(cmd.ErrOrStderr(), "Failed to sync contacts for '%s': %v\n", account, err)
+				if syncReport != "" {
+					// One JSON object per line and account
+					data, err := json.Marshal(rep)
+					if err != nil {
+						closeReport()
+						fatal(err)
+					}
+					if _, err := fmt.Fprintln(report, string(data)); err != nil {
+						closeReport()
+						fatal(fmt.Errorf("failed to write report: %w", err))
+					}
+				}
 			}
+			if syncVerify && ctx.Err() == nil && !verifyData(accounts) {
+				ok = false
+			}
+			return ok
+		}
+
+		if syncWatch {
+			watchSync(ctx, cycle)
+			closeReport()
+			return
+		}
+
+		ok := cycle()
+		closeReport()
+		if ctx.Err() != nil {
+			os.Exit(130)
+		}
+		// Every account was attempted; let cron notice partial failures
+		if !ok {
+			os.Exit(1)
 		}
 	},
 }
 
+// verifyData checks the synced files of the accounts, prints any problems
+// to stderr and reports whether there were none
+func verifyData(accounts []string) bool {
+	result, err := sync.Verify(cfg, accounts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: verify failed: %v\n", err)
+		return false
+	}
+	for _, p := range result.Problems {
+		fmt.Fprintf(os.Stderr, "Warning: %s: %s\n", p.File, p.Problem)
+	}
+	output.Progressf("Verified %d file(s): %d problem(s)\n", result.Files, len(result.Problems))
+	return len(result.Problems) == 0
+}
+
+// watchSync runs cycle now and then every --interval until ctx is done.
+// Ticks that fire while a cycle runs are skipped, and after repeated
+// failures the wait grows up to maxWatchBackoff intervals.
+func watchSync(ctx context.Context, cycle func() bool) {
+	ticker := time.NewTicker(syncInterval)
+	defer ticker.Stop()
+
+	failures := 0
+	var next time.Time
+	for {
+		output.Progressf("[%s] Starting sync cycle\n", time.Now().Format(time.DateTime))
+		if cycle() {
+			failures = 0
+			next = time.Time{}
+		} else if ctx.Err() == nil {
+			failures++
+			backoff := min(1<<(failures-1), maxWatchBackoff)
+			next = time.Now().Add(time.Duration(backoff) * syncInterval)
+			output.Progressf("[%s] Sync cycle failed (%d in a row); next cycle in %s\n",
+				time.Now().Format(time.DateTime), failures, time.Duration(backoff)*syncInterval)
+		}
+
+		// Drop a tick that fired during the cycle
+		select {
+		case <-ticker.C:
+			output.Progressf("Skipping a cycle: previous one was still running\n")
+		default:
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if !time.Now().Before(next) {
+				break
+			}
+		}
+	}
+}
+
+// syncOneAccount syncs the calendar and contacts of one account, printing
+// failures to stderr and collecting them in the report
+func syncOneAccount(cmd *cobra.Command, account string, opts sync.Options) *sync.AccountReport {
+	ctx := cmd.Context()
+	started := time.Now()
+	rep := &sync.AccountReport{Account: account}
+	defer func() { rep.DurationMS = time.Since(started).Milliseconds() }()
+
+	// Get Graph client
+	client, err := auth.NewGraphClient(cfg, account)
+	if err != nil {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Failed to sync '%s': %v\n", account, err)
+		rep.Errors = append(rep.Errors, err.Error())
+		return rep
+	}
+	client.Context = ctx
+	defer func() {
+		stats := client.Stats()
+		rep.API = &stats
+		if verbose {
+			fmt.Fprintf(cmd.ErrOrStderr(), "API for '%s': %d requests, %d retries, %d throttled\n",
+				account, stats.Requests, stats.Retries, stats.Throttled)
+		}
+	}()
+
+	// Sync calendar
+	rep.Calendar, err = sync.SyncCalendar(ctx, cfg, account, client, opts)
+	if err != nil {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Failed to sync calendar for '%s': %v\n", account, err)
+		rep.Errors = append(rep.Errors, "calendar: "+err.Error())
+	}
+
+	// Sync contacts
+	rep.Contacts, err = sync.SyncContacts(ctx, cfg, account, client, opts)
+	if err != nil {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Failed to sync contacts for '%s': %v\n", account, err)
+		rep.Errors = append(rep.Errors, "contacts: "+err.Error())
+	}
+
+	return rep
+}
+
 func init() {
 	syncCmd.Flags().StringVar(&syncAccount, "account", "", "Account to sync (or 'all' for all accounts)")
+	syncCmd.Flags().StringSliceVar(&syncAccounts, "accounts", nil, "Accounts to sync (comma-separated)")
+	syncCmd.Flags().BoolVar(&syncForce, "force", false, "Allow deleting more than half of the local events")
+	syncCmd.Flags().BoolVar(&syncAllowEmpty, "allow-empty", false, "Prune local events even if no events were returned")
+	syncCmd.Flags().BoolVar(&syncNoPrune, "no-prune", false, "Never delete local files; mark them 'deleted: true' instead")
+	syncCmd.Flags().StringVar(&syncReport, "report", "", "Print a machine-readable report per account instead of progress (json)")
+	syncCmd.Flags().StringVar(&syncReportFile, "report-file", "", "Write the JSON report to this file instead of stdout")
+	syncCmd.Flags().BoolVar(&syncWatch, "watch", false, "Keep running and re-sync every --interval until interrupted")
+	syncCmd.Flags().DurationVar(&syncInterval, "interval", 15*time.Minute, "Time between syncs with --watch")
+	syncCmd.Flags().StringVar(&syncSince, "since", "", "Only sync events on or after this date (YYYY-MM-DD); older local files are kept")
+	syncCmd.Flags().BoolVar(&syncFull, "full", false, "Refetch all contacts instead of using the delta link and drop local ones missing upstream")
+	syncCmd.Flags().BoolVar(&syncVerify, "verify", false, "After syncing, check files for duplicate ids, broken frontmatter and invalid dates")
+	syncCmd.Flags().BoolVar(&syncSinceLast, "since-last-sync", false, "Only sync events from the last sync on (default window on first sync)")
 }
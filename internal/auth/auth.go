@@ -1,11 +1,13 @@
 package auth
 
 import (
+	"bufio"
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -15,27 +17,52 @@ import (
 	"os/exec"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/lcorneliussen/md365/internal/config"
+	"github.com/lcorneliussen/md365/internal/graph"
+	"github.com/lcorneliussen/md365/internal/output"
 	"github.com/zalando/go-keyring"
 )
 
 const (
-	deviceCodeURL  = "https://login.microsoftonline.com/common/oauth2/v2.0/devicecode"
-	authorizeURL   = "https://login.microsoftonline.com/common/oauth2/v2.0/authorize"
-	tokenURL       = "https://login.microsoftonline.com/common/oauth2/v2.0/token"
+	authorityURL   = "https://login.microsoftonline.com"
 	tokenBuffer    = 5 * time.Minute // Auto-refresh 5 minutes before expiry
+	MaxClockSkew   = 2 * time.Minute // Warn if local clock differs more than this
 	keyringService = "md365"         // Service name for keyring storage
+
+	// FlowClientCredentials is the auth_flow for unattended app-only access
+	FlowClientCredentials = "clientcredentials"
+
+	// appScope requests all application permissions granted to the app
+	appScope = "https://graph.microsoft.com/.default"
 )
 
+// Reconsent makes GetAccessToken sign in again when the configured scopes
+// include ones the stored token lacks, instead of only warning
+var Reconsent bool
+
+// CallbackBind is the host[:port] the authcode flow's callback server
+// listens on (default 127.0.0.1 and a free port). Binding a fixed port makes
+// the flow usable over an SSH tunnel; non-loopback hosts expose it.
+var CallbackBind string
+
+// ManualCode makes the login use the authcode flow without a callback
+// server: the user pastes the redirected URL back into the terminal
+var ManualCode bool
+
+// scopeDriftWarned tracks accounts already warned about or re-consented
+var scopeDriftWarned = map[string]bool{}
+
 // Token represents an OAuth2 token
 type Token struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
 	ExpiresOn    int64  `json:"expires_on"`
 	Scope        string `json:"scope"`
+	UPN          string `json:"upn,omitempty"` // Signed-in user, resolved via /me after login
 }
 
 // DeviceCodeResponse represents the device code flow response
@@ -50,24 +77,126 @@ type DeviceCodeResponse struct {
 
 // TokenResponse represents the token response
 type TokenResponse struct {
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
-	ExpiresIn    int    `json:"expires_in"`
-	Scope        string `json:"scope,omitempty"`
-	Error        string `json:"error,omitempty"`
-	ErrorDesc    string `json:"error_description,omitempty"`
+	AccessToken   string `json:"access_token"`
+	RefreshToken  string `json:"refresh_token"`
+	ExpiresIn     int    `json:"expires_in"`
+	Scope         string `json:"scope,omitempty"`
+	Error         string `json:"error,omitempty"`
+	ErrorDesc     string `json:"error_description,omitempty"`
+	ErrorCodes    []int  `json:"error_codes,omitempty"`
+	Suberror      string `json:"suberror,omitempty"`
+	CorrelationID string `json:"correlation_id,omitempty"`
+	TraceID       string `json:"trace_id,omitempty"`
+}
+
+// errorDetails formats an error response including the diagnostic fields
+// Microsoft adds (AADSTS codes, suberror, correlation and trace IDs)
+func (t *TokenResponse) errorDetails() string {
+	msg := t.Error
+	if t.ErrorDesc != "" {
+		// The description already starts with the AADSTS code
+		msg += " - " + strings.TrimSpace(t.ErrorDesc)
+	}
+
+	var details []string
+	if len(t.ErrorCodes) > 0 {
+		codes := make([]string, len(t.ErrorCodes))
+		for i, code := range t.ErrorCodes {
+			codes[i] = strconv.Itoa(code)
+		}
+		details = append(details, "error_codes: "+strings.Join(codes, ","))
+	}
+	if t.Suberror != "" {
+		details = append(details, "suberror: "+t.Suberror)
+	}
+	if t.CorrelationID != "" {
+		details = append(details, "correlation_id: "+t.CorrelationID)
+	}
+	if t.TraceID != "" {
+		details = append(details, "trace_id: "+t.TraceID)
+	}
+	if len(details) > 0 {
+		msg += " (" + strings.Join(details, "; ") + ")"
+	}
+	return msg
+}
+
+// addClientAuth adds the client credentials of a confidential app (a
+// client_secret from client_secret_env, or a certificate-signed client
+// assertion from cert_path) to a token request. Public clients send none.
+func addClientAuth(cfg *config.Config, account string, form url.Values) error {
+	secret, err := cfg.GetClientSecret(account)
+	if err != nil {
+		return err
+	}
+	certPath, keyPath := cfg.GetCertPaths(account)
+	if secret != "" && certPath != "" {
+		return fmt.Errorf("account '%s' sets both client_secret_env and cert_path; use one", account)
+	}
+	if secret != "" {
+		form.Set("client_secret", secret)
+	}
+	if certPath != "" {
+		assertion, err := clientAssertion(certPath, keyPath, cfg.GetClientID(account), endpointURL(cfg, "token"))
+		if err != nil {
+			return err
+		}
+		form.Set("client_assertion_type", clientAssertionType)
+		form.Set("client_assertion", assertion)
+	}
+	return nil
+}
+
+// endpointURL returns the OAuth2 endpoint URL for the configured tenant
+func endpointURL(cfg *config.Config, endpoint string) string {
+	return fmt.Sprintf("%s/%s/oauth2/v2.0/%s", authorityURL, endpointTenant(cfg), endpoint)
+}
+
+// endpointTenant returns the configured tenant, or the default one
+func endpointTenant(cfg *config.Config) string {
+	if cfg.Tenant == "" {
+		return config.DefaultTenant
+	}
+	return cfg.Tenant
 }
 
 // GetAccessToken returns a valid access token for the account, refreshing if needed
 func GetAccessToken(cfg *config.Config, account string) (string, error) {
+	appOnly := cfg.GetAuthFlow(account) == FlowClientCredentials
+
 	token, err := loadToken(account)
+	if err != nil && appOnly {
+		// Unattended: app-only tokens need no sign-in
+		if err := fetchAppToken(cfg, account); err != nil {
+			return "", err
+		}
+		token, err = loadToken(account)
+	}
 	if err != nil {
 		return "", fmt.Errorf("no token found for account '%s'. Run: md365 auth login --account %s", account, account)
 	}
 
+	// Scopes added to the config after login are not in the token. Warn or
+	// re-consent at most once per run, since the tenant may keep refusing one.
+	if missing := missingScopes(cfg, account, token.Scope); len(missing) > 0 && !appOnly && !scopeDriftWarned[account] {
+		scopeDriftWarned[account] = true
+		if Reconsent {
+			output.Progressf("Account '%s' is configured for new scopes (%s), signing in again...\n", account, strings.Join(missing, " "))
+			if err := DispatchLogin(cfg, account, "", nil); err != nil {
+				return "", fmt.Errorf("failed to re-consent: %w", err)
+			}
+			if token, err = loadToken(account); err != nil {
+				return "", err
+			}
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: account '%s' is configured for scopes its token lacks: %s\n", account, strings.Join(missing, " "))
+			fmt.Fprintf(os.Stderr, "Run: md365 auth login --account %s (or pass --reconsent)\n", account)
+		}
+	}
+
 	// Check if token needs refresh
 	if time.Now().Add(tokenBuffer).Unix() >= token.ExpiresOn {
-		fmt.Fprintf(os.Stderr, "Refreshing token for account '%s'...\n", account)
+		output.Progressf("Refreshing token for account '%s'...\n", account)
 		if err := RefreshToken(cfg, account); err != nil {
 			return "", fmt.Errorf("failed to refresh token: %w", err)
 		}
@@ -81,8 +210,40 @@ func GetAccessToken(cfg *config.Config, account string) (string, error) {
 	return token.AccessToken, nil
 }
 
+// NewGraphClient returns a Graph client for the account that refreshes its
+// token once and retries if a request is rejected with HTTP 401
+func NewGraphClient(cfg *config.Config, account string) (*graph.Client, error) {
+	token, err := GetAccessToken(cfg, account)
+	if err != nil {
+		return nil, err
+	}
+
+	client := graph.NewClient(token)
+	client.BaseURL = graph.VersionURL(cfg.GraphVersion)
+	if acc, err := cfg.GetAccount(account); err == nil {
+		client.User = acc.UserID
+	}
+	client.Refresh = func() (string, error) {
+		if err := RefreshToken(cfg, account); err != nil {
+			return "", err
+		}
+		token, err := loadToken(account)
+		if err != nil {
+			return "", err
+		}
+		return token.AccessToken, nil
+	}
+
+	return client, nil
+}
+
 // RefreshToken refreshes the access token for an account
 func RefreshToken(cfg *config.Config, account string) error {
+	// App-only tokens have no refresh token; request a new one
+	if cfg.GetAuthFlow(account) == FlowClientCredentials {
+		return fetchAppToken(cfg, account)
+	}
+
 	token, err := loadToken(account)
 	if err != nil {
 		return fmt.Errorf("no token found for account '%s'", account)
@@ -94,8 +255,11 @@ func RefreshToken(cfg *config.Config, account string) error {
 		"refresh_token": {token.RefreshToken},
 		"grant_type":    {"refresh_token"},
 	}
+	if err := addClientAuth(cfg, account, data); err != nil {
+		return err
+	}
 
-	resp, err := http.PostForm(tokenURL, data)
+	resp, err := http.PostForm(endpointURL(cfg, "token"), data)
 	if err != nil {
 		return fmt.Errorf("failed to refresh token: %w", err)
 	}
@@ -112,7 +276,7 @@ func RefreshToken(cfg *config.Config, account string) error {
 	}
 
 	if tokenResp.Error != "" {
-		return fmt.Errorf("error refreshing token: %s - %s", tokenResp.Error, tokenResp.ErrorDesc)
+		return fmt.Errorf("error refreshing token: %s", tokenResp.errorDetails())
 	}
 
 	// Save new token - use granted scopes from response, fallback to existing if not provided
@@ -126,13 +290,14 @@ func RefreshToken(cfg *config.Config, account string) error {
 		RefreshToken: tokenResp.RefreshToken,
 		ExpiresOn:    time.Now().Unix() + int64(tokenResp.ExpiresIn),
 		Scope:        grantedScope,
+		UPN:          token.UPN,
 	}
 
 	if err := saveToken(account, &newToken); err != nil {
 		return fmt.Errorf("failed to save token: %w", err)
 	}
 
-	fmt.Fprintln(os.Stderr, "Token refreshed successfully")
+	output.Progressf("Token refreshed successfully\n")
 	return nil
 }
 
@@ -151,7 +316,7 @@ func Login(cfg *config.Config, account string, scope string) error {
 		"scope":     {scope},
 	}
 
-	resp, err := http.PostForm(deviceCodeURL, data)
+	resp, err := http.PostForm(endpointURL(cfg, "devicecode"), data)
 	if err != nil {
 		return fmt.Errorf("failed to initiate device code flow: %w", err)
 	}
@@ -167,6 +332,15 @@ func Login(cfg *config.Config, account string, scope string) error {
 		return fmt.Errorf("failed to parse response: %w", err)
 	}
 
+	// Errors (e.g. an unknown client or tenant) come back in the token error shape
+	if deviceResp.DeviceCode == "" {
+		var errResp TokenResponse
+		if json.Unmarshal(body, &errResp) == nil && errResp.Error != "" {
+			return fmt.Errorf("device code error: %s", errResp.errorDetails())
+		}
+		return fmt.Errorf("no device code in response (HTTP %d)", resp.StatusCode)
+	}
+
 	// Build direct login URL with pre-filled code
 	directURL := fmt.Sprintf("%s?otc=%s", deviceResp.VerificationURI, deviceResp.UserCode)
 
@@ -196,8 +370,11 @@ func Login(cfg *config.Config, account string, scope string) error {
 			"device_code": {deviceResp.DeviceCode},
 			"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
 		}
+		if err := addClientAuth(cfg, account, tokenData); err != nil {
+			return err
+		}
 
-		tokenResp, err := http.PostForm(tokenURL, tokenData)
+		tokenResp, err := http.PostForm(endpointURL(cfg, "token"), tokenData)
 		if err != nil {
 			return fmt.Errorf("failed to poll for token: %w", err)
 		}
@@ -241,13 +418,50 @@ func Login(cfg *config.Config, account string, scope string) error {
 			fmt.Printf("Successfully authenticated account '%s'\n", account)
 			return nil
 		default:
-			return fmt.Errorf("error: %s - %s", token.Error, token.ErrorDesc)
+			return fmt.Errorf("error: %s", token.errorDetails())
 		}
 	}
 
 	return fmt.Errorf("authentication timed out")
 }
 
+// ClockSkew returns how far the local clock is ahead of the Microsoft login
+// server, based on the Date header of a lightweight request
+func ClockSkew() (time.Duration, error) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Head(authorityURL)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	serverTime, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return 0, fmt.Errorf("no usable Date header: %w", err)
+	}
+
+	// Date has second precision
+	return time.Since(serverTime).Truncate(time.Second), nil
+}
+
+// warnClockSkew prints a warning if the local clock is off by more than
+// MaxClockSkew, since that makes tokens look expired/valid incorrectly
+func warnClockSkew() {
+	skew, err := ClockSkew()
+	if err != nil {
+		return
+	}
+	if skew > MaxClockSkew || skew < -MaxClockSkew {
+		direction := "ahead of"
+		if skew < 0 {
+			direction = "behind"
+			skew = -skew
+		}
+		fmt.Fprintf(os.Stderr, "Warning: local clock is %s %s Microsoft's servers. Token expiry and login may fail; sync your system clock.\n",
+			skew, direction)
+	}
+}
+
 // generateCodeVerifier generates a PKCE code verifier (43-128 chars, URL-safe)
 func generateCodeVerifier() (string, error) {
 	b := make([]byte, 32)
@@ -274,8 +488,95 @@ func getFreePort() (int, error) {
 	return listener.Addr().(*net.TCPAddr).Port, nil
 }
 
+// readPastedCode prints the authorization URL and reads the redirected URL
+// (or the bare code) the user pastes back from the browser
+func readPastedCode(authURL, hint string) (string, error) {
+	fmt.Println()
+	fmt.Println("Open this URL in any browser and sign in:")
+	fmt.Printf("  %s\n", authURL)
+	if hint != "" {
+		fmt.Printf("Account hint: %s\n", hint)
+	}
+	fmt.Println()
+	fmt.Println("The browser then fails to load a http://localhost page; that is expected.")
+	fmt.Print("Paste the full URL from its address bar: ")
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read redirected URL: %w", err)
+	}
+	return parseRedirectCode(strings.TrimSpace(line))
+}
+
+// parseRedirectCode extracts the authorization code from a pasted redirect
+// URL; input without a query is taken as the code itself
+func parseRedirectCode(input string) (string, error) {
+	if input == "" {
+		return "", fmt.Errorf("no redirected URL given")
+	}
+	if !strings.Contains(input, "?") {
+		return input, nil
+	}
+
+	u, err := url.Parse(input)
+	if err != nil {
+		return "", fmt.Errorf("invalid redirected URL: %w", err)
+	}
+	query := u.Query()
+	if errParam := query.Get("error"); errParam != "" {
+		return "", fmt.Errorf("authorization error: %s - %s", errParam, query.Get("error_description"))
+	}
+	code := query.Get("code")
+	if code == "" {
+		return "", fmt.Errorf("no authorization code in the redirected URL")
+	}
+	return code, nil
+}
+
+// callbackAddr resolves CallbackBind to the host and port the callback
+// server listens on, warning if it is reachable from other machines
+func callbackAddr() (string, int, error) {
+	host, portStr := CallbackBind, ""
+	if strings.Contains(CallbackBind, ":") {
+		var err error
+		if host, portStr, err = net.SplitHostPort(CallbackBind); err != nil {
+			return "", 0, fmt.Errorf("invalid --bind address %q: %w", CallbackBind, err)
+		}
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		fmt.Fprintf(os.Stderr, "WARNING: the sign-in callback listens on %s and is reachable from the network.\n", host)
+		fmt.Fprintln(os.Stderr, "Anyone who can connect while you sign in could inject an authorization code. Prefer an SSH tunnel to 127.0.0.1.")
+	}
+
+	if portStr == "" || portStr == "0" {
+		port, err := getFreePort()
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to find free port: %w", err)
+		}
+		return host, port, nil
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return "", 0, fmt.Errorf("invalid --bind port %q", portStr)
+	}
+	return host, port, nil
+}
+
 // DispatchLogin performs authentication using the configured flow for the account
 func DispatchLogin(cfg *config.Config, account string, scopeOverride string, addScopes []string) error {
+	warnClockSkew()
+
+	// Fail before the user signs in if a confidential app's secret or
+	// certificate is missing or unusable
+	if err := addClientAuth(cfg, account, url.Values{}); err != nil {
+		return err
+	}
+
 	// Determine final scopes based on priority
 	var finalScope string
 
@@ -315,14 +616,61 @@ func DispatchLogin(cfg *config.Config, account string, scopeOverride string, add
 	}
 
 	authFlow := cfg.GetAuthFlow(account)
+	if ManualCode {
+		authFlow = "authcode"
+	}
+	var err error
 	switch authFlow {
 	case "authcode":
-		return LoginAuthCode(cfg, account, finalScope)
+		err = LoginAuthCode(cfg, account, finalScope)
 	case "devicecode":
-		return Login(cfg, account, finalScope)
+		err = Login(cfg, account, finalScope)
+	case FlowClientCredentials:
+		err = LoginClientCredentials(cfg, account)
 	default:
-		return fmt.Errorf("unknown auth_flow '%s' for account '%s'. Valid values: devicecode, authcode", authFlow, account)
+		return fmt.Errorf("unknown auth_flow '%s' for account '%s'. Valid values: devicecode, authcode, clientcredentials", authFlow, account)
+	}
+	if err != nil {
+		return err
+	}
+
+	verifyIdentity(cfg, account)
+	return nil
+}
+
+// verifyIdentity resolves the signed-in user via /me, stores the UPN with
+// the token and warns if it doesn't match the account's configured hint.
+// Skipped if User.Read was not granted.
+func verifyIdentity(cfg *config.Config, account string) {
+	token, err := loadToken(account)
+	if err != nil || !hasScope(token.Scope, "User.Read") {
+		return
+	}
+
+	user, err := GetMe(cfg, account)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not verify signed-in user: %v\n", err)
+		return
+	}
+
+	token.UPN = user.UserPrincipalName
+	if err := saveToken(account, token); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save token: %v\n", err)
+	}
+
+	acc, err := cfg.GetAccount(account)
+	if err != nil || acc.Hint == "" {
+		output.Infof("Signed in as %s\n", user.UserPrincipalName)
+		return
 	}
+
+	if !strings.EqualFold(acc.Hint, user.UserPrincipalName) && !strings.EqualFold(acc.Hint, user.Mail) {
+		fmt.Fprintf(os.Stderr, "\nWARNING: account '%s' expects %s, but you signed in as %s.\n", account, acc.Hint, user.UserPrincipalName)
+		fmt.Fprintf(os.Stderr, "If this is wrong, log in again with the right user: md365 auth login --account %s\n\n", account)
+		return
+	}
+
+	output.Infof("Signed in as %s\n", user.UserPrincipalName)
 }
 
 // LoginAuthCode performs authorization code flow with PKCE
@@ -341,16 +689,17 @@ func LoginAuthCode(cfg *config.Config, account string, scope string) error {
 	}
 	codeChallenge := generateCodeChallenge(codeVerifier)
 
-	// Get a free port
-	port, err := getFreePort()
+	bindHost, port, err := callbackAddr()
 	if err != nil {
-		return fmt.Errorf("failed to find free port: %w", err)
+		return err
 	}
 
+	// Entra ID only accepts loopback redirects for public clients, so the
+	// redirect stays on localhost even when listening on another interface
 	redirectURI := fmt.Sprintf("http://localhost:%d", port)
 
 	// Build authorization URL
-	authURL, err := url.Parse(authorizeURL)
+	authURL, err := url.Parse(endpointURL(cfg, "authorize"))
 	if err != nil {
 		return fmt.Errorf("failed to parse authorize URL: %w", err)
 	}
@@ -368,6 +717,14 @@ func LoginAuthCode(cfg *config.Config, account string, scope string) error {
 	}
 	authURL.RawQuery = params.Encode()
 
+	if ManualCode {
+		authCode, err := readPastedCode(authURL.String(), acc.Hint)
+		if err != nil {
+			return err
+		}
+		return exchangeAuthCode(cfg, account, scope, authCode, redirectURI, codeVerifier)
+	}
+
 	// Channel to receive authorization code or error
 	resultCh := make(chan string, 1)
 	errorCh := make(chan error, 1)
@@ -399,7 +756,7 @@ func LoginAuthCode(cfg *config.Config, account string, scope string) error {
 	})
 
 	server := &http.Server{
-		Addr:    fmt.Sprintf("127.0.0.1:%d", port),
+		Addr:    net.JoinHostPort(bindHost, fmt.Sprint(port)),
 		Handler: mux,
 	}
 
@@ -426,6 +783,11 @@ func LoginAuthCode(cfg *config.Config, account string, scope string) error {
 	if acc.Hint != "" {
 		fmt.Printf("Account hint: %s\n", acc.Hint)
 	}
+	if CallbackBind != "" {
+		fmt.Println()
+		fmt.Printf("Callback server listening on %s. From another machine, forward the port first:\n", server.Addr)
+		fmt.Printf("  ssh -L %d:localhost:%d <this-host>\n", port, port)
+	}
 	fmt.Println()
 	fmt.Println("Waiting for authentication...")
 
@@ -445,7 +807,11 @@ func LoginAuthCode(cfg *config.Config, account string, scope string) error {
 		return fmt.Errorf("authentication timed out")
 	}
 
-	// Exchange code for token
+	return exchangeAuthCode(cfg, account, scope, authCode, redirectURI, codeVerifier)
+}
+
+// exchangeAuthCode redeems an authorization code for a token and saves it
+func exchangeAuthCode(cfg *config.Config, account, scope, authCode, redirectURI, codeVerifier string) error {
 	tokenData := url.Values{
 		"client_id":     {cfg.GetClientID(account)},
 		"grant_type":    {"authorization_code"},
@@ -453,8 +819,11 @@ func LoginAuthCode(cfg *config.Config, account string, scope string) error {
 		"redirect_uri":  {redirectURI},
 		"code_verifier": {codeVerifier},
 	}
+	if err := addClientAuth(cfg, account, tokenData); err != nil {
+		return err
+	}
 
-	resp, err := http.PostForm(tokenURL, tokenData)
+	resp, err := http.PostForm(endpointURL(cfg, "token"), tokenData)
 	if err != nil {
 		return fmt.Errorf("failed to exchange code for token: %w", err)
 	}
@@ -471,7 +840,7 @@ func LoginAuthCode(cfg *config.Config, account string, scope string) error {
 	}
 
 	if tokenResp.Error != "" {
-		return fmt.Errorf("token error: %s - %s", tokenResp.Error, tokenResp.ErrorDesc)
+		return fmt.Errorf("token error: %s", tokenResp.errorDetails())
 	}
 
 	// Save token - use granted scopes from response, fallback to requested if not provided
@@ -496,50 +865,172 @@ func LoginAuthCode(cfg *config.Config, account string, scope string) error {
 	return nil
 }
 
+// LoginClientCredentials obtains an app-only token for an account with
+// auth_flow clientcredentials and reports the result
+func LoginClientCredentials(cfg *config.Config, account string) error {
+	fmt.Printf("Requesting app-only token for account '%s'...\n", account)
+	if err := fetchAppToken(cfg, account); err != nil {
+		return err
+	}
+	fmt.Printf("Successfully authenticated account '%s' (app-only)\n", account)
+	return nil
+}
+
+// fetchAppToken requests a token with the client credentials grant and
+// saves it. App-only tokens carry the app's application permissions
+// (.default) and act on the mailbox named by user_id.
+func fetchAppToken(cfg *config.Config, account string) error {
+	acc, err := cfg.GetAccount(account)
+	if err != nil {
+		return err
+	}
+	if acc.UserID == "" {
+		return fmt.Errorf("account '%s' uses auth_flow %s and needs user_id (app-only tokens have no /me)", account, FlowClientCredentials)
+	}
+	if tenant := cfg.Tenant; tenant == "" || tenant == config.DefaultTenant || tenant == "organizations" || tenant == "consumers" {
+		return fmt.Errorf("auth_flow %s needs a specific tenant (id or domain), not '%s'", FlowClientCredentials, endpointTenant(cfg))
+	}
+
+	data := url.Values{
+		"client_id":  {cfg.GetClientID(account)},
+		"grant_type": {"client_credentials"},
+		"scope":      {appScope},
+	}
+	if err := addClientAuth(cfg, account, data); err != nil {
+		return err
+	}
+	if data.Get("client_secret") == "" && data.Get("client_assertion") == "" {
+		return fmt.Errorf("account '%s' uses auth_flow %s and needs client credentials (client_secret_env or cert_path)", account, FlowClientCredentials)
+	}
+
+	resp, err := http.PostForm(endpointURL(cfg, "token"), data)
+	if err != nil {
+		return fmt.Errorf("failed to request app-only token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read token response: %w", err)
+	}
+
+	var tokenResp TokenResponse
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return fmt.Errorf("failed to parse token response: %w", err)
+	}
+	if tokenResp.Error != "" {
+		return fmt.Errorf("token error: %s", tokenResp.errorDetails())
+	}
+
+	token := Token{
+		AccessToken: tokenResp.AccessToken,
+		ExpiresOn:   time.Now().Unix() + int64(tokenResp.ExpiresIn),
+		Scope:       appScope,
+	}
+	if err := saveToken(account, &token); err != nil {
+		return fmt.Errorf("failed to save token: %w", err)
+	}
+	return nil
+}
+
+// AccountStatus represents the authentication status of an account
+type AccountStatus struct {
+	Account   string   `json:"account"`
+	AuthFlow  string   `json:"auth_flow"`
+	Status    string   `json:"status"` // valid, expired, not_authenticated
+	User      string   `json:"user,omitempty"`
+	ExpiresOn string   `json:"expires_on,omitempty"`
+	Scopes    []string `json:"scopes,omitempty"`
+	Disabled  bool     `json:"disabled,omitempty"`
+}
+
 // Status shows authentication status for all accounts
 func Status(cfg *config.Config) {
+	accounts := cfg.ListAccounts()
+	sort.Strings(accounts)
+
+	statuses := make([]AccountStatus, 0, len(accounts))
+	for _, account := range accounts {
+		status := AccountStatus{
+			Account:  account,
+			AuthFlow: cfg.GetAuthFlow(account),
+			Status:   "not_authenticated",
+			Disabled: !cfg.AccountEnabled(account),
+		}
+
+		if token, err := loadToken(account); err == nil {
+			status.Status = "expired"
+			if token.ExpiresOn > time.Now().Unix() {
+				status.Status = "valid"
+			}
+			status.User = token.UPN
+			status.ExpiresOn = time.Unix(token.ExpiresOn, 0).Format(time.RFC3339)
+			status.Scopes = parseScopes(token.Scope)
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	warnClockSkew()
+
+	if output.JSON() {
+		output.PrintJSON(statuses)
+		return
+	}
+
 	fmt.Println("Account authentication status:")
 	fmt.Println()
 
-	for _, account := range cfg.ListAccounts() {
-		authFlow := cfg.GetAuthFlow(account)
-		token, err := loadToken(account)
-		if err != nil {
-			fmt.Printf("  %s: NOT AUTHENTICATED [%s]\n", account, authFlow)
+	for _, status := range statuses {
+		name := status.Account
+		if status.Disabled {
+			name += " [disabled]"
+		}
+
+		switch status.Status {
+		case "not_authenticated":
+			fmt.Printf("  %s: %s [%s]\n", name, output.Yellow("NOT AUTHENTICATED"), status.AuthFlow)
 			continue
+		case "valid":
+			expiresOn, _ := time.Parse(time.RFC3339, status.ExpiresOn)
+			hours := int(time.Until(expiresOn).Hours())
+			fmt.Printf("  %s: %s [%s]\n", name, output.Green(fmt.Sprintf("Valid (expires in %dh)", hours)), status.AuthFlow)
+		default:
+			fmt.Printf("  %s: %s [%s]\n", name, output.Red("EXPIRED"), status.AuthFlow)
 		}
 
-		if token.ExpiresOn > time.Now().Unix() {
-			remaining := time.Duration(token.ExpiresOn-time.Now().Unix()) * time.Second
-			hours := int(remaining.Hours())
-			fmt.Printf("  %s: Valid (expires in %dh) [%s]\n", account, hours, authFlow)
-			// Show scopes
-			if token.Scope != "" {
-				fmt.Printf("    Scopes: %s\n", token.Scope)
-			}
-		} else {
-			fmt.Printf("  %s: EXPIRED [%s]\n", account, authFlow)
-			// Show scopes even if expired
-			if token.Scope != "" {
-				fmt.Printf("    Scopes: %s\n", token.Scope)
-			}
+		if status.User != "" {
+			fmt.Printf("    User:   %s\n", status.User)
+		}
+
+		// Show scopes, even if expired
+		if len(status.Scopes) > 0 {
+			fmt.Printf("    Scopes: %s\n", strings.Join(status.Scopes, " "))
 		}
 	}
 }
 
 // tokenFilePath returns the file path for file-based token storage
 func tokenFilePath(account string) string {
-	xdgConfig := os.Getenv("XDG_CONFIG_HOME")
-	if xdgConfig == "" {
-		xdgConfig = filepath.Join(os.Getenv("HOME"), ".config")
+	dir := config.GetBaseConfigDir()
+	if profile := config.GetProfile(); profile != "" {
+		dir = filepath.Join(dir, "profiles", profile)
+	}
+	return filepath.Join(dir, "tokens", account+".json")
+}
+
+// keyringServiceName returns the keyring service of the active profile
+func keyringServiceName() string {
+	if profile := config.GetProfile(); profile != "" {
+		return keyringService + ":" + profile
 	}
-	return filepath.Join(xdgConfig, "md365", "tokens", account+".json")
+	return keyringService
 }
 
 // loadToken loads a token from keyring, falling back to file
 func loadToken(account string) (*Token, error) {
 	// Try keyring first
-	tokenJSON, err := keyring.Get(keyringService, account)
+	tokenJSON, err := keyring.Get(keyringServiceName(), account)
 	if err == nil {
 		var token Token
 		if err := json.Unmarshal([]byte(tokenJSON), &token); err != nil {
@@ -569,7 +1060,7 @@ func saveToken(account string, token *Token) error {
 	}
 
 	// Try keyring first
-	if err := keyring.Set(keyringService, account, string(data)); err != nil {
+	if err := keyring.Set(keyringServiceName(), account, string(data)); err != nil {
 		// Fall back to file storage
 		fmt.Fprintf(os.Stderr, "Warning: keyring storage failed, using file fallback: %v\n", err)
 		return saveTokenFile(account, data)
@@ -585,7 +1076,7 @@ func saveTokenFile(account string, data []byte) error {
 	if err := os.MkdirAll(dir, 0700); err != nil {
 		return fmt.Errorf("failed to create token directory: %w", err)
 	}
-	if err := os.WriteFile(path, data, 0600); err != nil {
+	if err := AtomicWriteFile(path, data, 0600); err != nil {
 		return fmt.Errorf("failed to write token file: %w", err)
 	}
 	return nil
@@ -615,9 +1106,9 @@ func Slugify(text string, maxLen int) string {
 	// Trim dashes
 	slug = strings.Trim(slug, "-")
 
-	// Truncate
-	if len(slug) > maxLen {
-		slug = slug[:maxLen]
+	// Truncate by runes, never mid-character
+	if runes := []rune(slug); len(runes) > maxLen {
+		slug = strings.TrimRight(string(runes[:maxLen]), "-")
 	}
 
 	return slug
@@ -639,9 +1130,65 @@ func GenerateUniqueFilename(dir, baseName, ext string) string {
 	}
 }
 
+// AtomicWriteFile writes data to a temp file in the same directory and renames
+// it into place, so an interrupted write never leaves a truncated file behind
+func AtomicWriteFile(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// CheckKeyring verifies the system keyring works by writing, reading and
+// deleting a probe entry
+func CheckKeyring() error {
+	const probe = "md365-doctor-probe"
+
+	if err := keyring.Set(keyringServiceName(), probe, "ok"); err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+	value, err := keyring.Get(keyringServiceName(), probe)
+	if err != nil {
+		return fmt.Errorf("read failed: %w", err)
+	}
+	if err := keyring.Delete(keyringServiceName(), probe); err != nil {
+		return fmt.Errorf("delete failed: %w", err)
+	}
+	if value != "ok" {
+		return fmt.Errorf("read back %q instead of the probe value", value)
+	}
+	return nil
+}
+
 // DeleteToken removes a token from keyring
 func DeleteToken(account string) error {
-	return keyring.Delete(keyringService, account)
+	return keyring.Delete(keyringServiceName(), account)
 }
 
 // parseScopes splits a scope string into individual scopes
@@ -665,6 +1212,38 @@ func normalizeScope(scope string) string {
 	return strings.ToLower(strings.TrimSpace(scope))
 }
 
+// scopePresets are the named scope sets accepted by --scope-preset
+var scopePresets = map[string][]string{
+	"calendar": {"Calendars.ReadWrite", "User.Read"},
+	"contacts": {"Contacts.ReadWrite", "User.Read"},
+	"mail":     {"Mail.Send", "User.Read"},
+	"full":     {"Calendars.ReadWrite", "Contacts.ReadWrite", "Mail.Send", "User.Read"},
+}
+
+// PresetScopes expands scope preset names (calendar, contacts, mail, full)
+// into their scopes, without duplicates
+func PresetScopes(names []string) ([]string, error) {
+	var scopes []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		preset, ok := scopePresets[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown scope preset '%s'. Valid presets: calendar, contacts, mail, full", name)
+		}
+		for _, scope := range preset {
+			if !seen[scope] {
+				seen[scope] = true
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	return scopes, nil
+}
+
 // mergeScopes merges multiple scope lists, deduplicating (case-insensitive)
 // Always ensures offline_access is included
 func mergeScopes(scopeLists ...[]string) string {
@@ -715,3 +1294,130 @@ func ShowScopes(account string) error {
 
 	return nil
 }
+
+// missingScopes returns the configured scopes of an account that are not in
+// the token scope, ignoring offline_access
+func missingScopes(cfg *config.Config, account, tokenScope string) []string {
+	acc, err := cfg.GetAccount(account)
+	if err != nil || tokenScope == "" {
+		return nil
+	}
+
+	var missing []string
+	for _, scope := range parseScopes(acc.Scope) {
+		if normalizeScope(scope) != "offline_access" && !hasScope(tokenScope, scope) {
+			missing = append(missing, scope)
+		}
+	}
+	return missing
+}
+
+// CheckGrantedScopes compares the scopes of an account's token with the
+// requested ones, prints the granted ones and warns about any that were dropped
+func CheckGrantedScopes(account, requested string) error {
+	token, err := loadToken(account)
+	if err != nil {
+		return fmt.Errorf("no token found for account '%s': %w", account, err)
+	}
+
+	// App-only tokens carry the app's application permissions, not the
+	// delegated scopes of the config
+	if token.Scope == appScope {
+		output.Infof("App-only token: the application permissions granted to the app apply\n")
+		return nil
+	}
+
+	var granted, missing []string
+	for _, scope := range parseScopes(requested) {
+		// offline_access is not always echoed back in the token scope
+		if normalizeScope(scope) == "offline_access" {
+			continue
+		}
+		if hasScope(token.Scope, scope) {
+			granted = append(granted, scope)
+		} else {
+			missing = append(missing, scope)
+		}
+	}
+
+	if len(granted) > 0 {
+		output.Infof("Granted scopes: %s\n", strings.Join(granted, " "))
+	}
+	if len(missing) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: requested scopes not granted: %s\n", strings.Join(missing, " "))
+		fmt.Fprintf(os.Stderr, "The app registration or tenant may not allow them; commands needing them will fail.\n")
+	}
+
+	return nil
+}
+
+// hasScope reports whether a token scope string contains scope, also
+// matching resource-prefixed forms like "https://graph.microsoft.com/User.Read"
+func hasScope(scopeStr, scope string) bool {
+	want := normalizeScope(scope)
+	for _, s := range parseScopes(scopeStr) {
+		s = normalizeScope(s)
+		if s == want || strings.HasSuffix(s, "/"+want) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetMe fetches the signed-in user of an account via /me
+func GetMe(cfg *config.Config, account string) (*graph.User, error) {
+	token, err := loadToken(account)
+	if err != nil {
+		return nil, fmt.Errorf("no token found for account '%s'. Run: md365 auth login --account %s", account, account)
+	}
+	// App-only tokens (.default) read /users/{user_id} with User.Read.All
+	appOnly := token.Scope == appScope
+	if token.Scope != "" && !appOnly && !hasScope(token.Scope, "User.Read") {
+		return nil, fmt.Errorf("account '%s' was not granted User.Read. Run: md365 auth login --account %s --add-scope User.Read", account, account)
+	}
+
+	client, err := NewGraphClient(cfg, account)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := client.GetMe()
+	if err != nil {
+		var apiErr *graph.APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusForbidden {
+			if appOnly {
+				return nil, fmt.Errorf("app of account '%s' lacks the User.Read.All application permission: %w", account, err)
+			}
+			return nil, fmt.Errorf("account '%s' lacks the User.Read permission: %w", account, err)
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// WhoAmI prints the identity the account's token resolves to
+func WhoAmI(cfg *config.Config, account string) error {
+	user, err := GetMe(cfg, account)
+	if err != nil {
+		return err
+	}
+
+	if output.JSON() {
+		return output.PrintJSON(map[string]string{
+			"account":             account,
+			"display_name":        user.DisplayName,
+			"user_principal_name": user.UserPrincipalName,
+			"mail":                user.Mail,
+		})
+	}
+
+	fmt.Printf("Account:      %s\n", account)
+	fmt.Printf("Display name: %s\n", user.DisplayName)
+	fmt.Printf("UPN:          %s\n", user.UserPrincipalName)
+	if user.Mail != "" {
+		fmt.Printf("Mail:         %s\n", user.Mail)
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     string
+		maxLen int
+		want   string
+	}{
+		{"plain", "Team Sync", 60, "team-sync"},
+		{"accents", "Réunion d'équipe", 60, "r-union-d-quipe"},
+		{"emoji", "🎉 Launch party 🚀", 60, "launch-party"},
+		{"cut on dash", "Team Sync", 5, "team"},
+		{"cut at limit", "Café meeting", 3, "caf"},
+		{"only emoji", "🎉🚀", 60, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Slugify(tt.in, tt.maxLen)
+			if got != tt.want {
+				t.Errorf("Slugify(%q, %d) = %q, want %q", tt.in, tt.maxLen, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("Slugify(%q, %d) = %q is not valid UTF-8", tt.in, tt.maxLen, got)
+			}
+			if n := utf8.RuneCountInString(got); n > tt.maxLen {
+				t.Errorf("Slugify(%q, %d) has %d runes", tt.in, tt.maxLen, n)
+			}
+		})
+	}
+}
+
+// Every cut of a multi-byte subject stays valid UTF-8 within the limit
+func TestSlugifyBoundaries(t *testing.T) {
+	subject := "Ünïcødé 🎉 résumé ✓"
+	for maxLen := 0; maxLen <= utf8.RuneCountInString(subject)+1; maxLen++ {
+		got := Slugify(subject, maxLen)
+		if !utf8.ValidString(got) || utf8.RuneCountInString(got) > maxLen {
+			t.Errorf("Slugify(%q, %d) = %q", subject, maxLen, got)
+		}
+	}
+}
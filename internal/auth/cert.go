@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	clientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+	assertionLifetime   = 10 * time.Minute
+)
+
+// clientAssertion builds a JWT signed with the certificate's private key,
+// proving the app's identity to the token endpoint (audience) in place of a
+// client secret
+func clientAssertion(certPath, keyPath, clientID, audience string) (string, error) {
+	cert, key, err := loadCertificate(certPath, keyPath)
+	if err != nil {
+		return "", err
+	}
+
+	// x5t identifies the uploaded certificate by its SHA-1 thumbprint
+	thumbprint := sha1.Sum(cert.Raw)
+	header := map[string]string{
+		"alg": "RS256",
+		"typ": "JWT",
+		"x5t": base64.RawURLEncoding.EncodeToString(thumbprint[:]),
+	}
+
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", fmt.Errorf("failed to generate assertion id: %w", err)
+	}
+	now := time.Now()
+	claims := map[string]any{
+		"aud": audience,
+		"iss": clientID,
+		"sub": clientID,
+		"jti": base64.RawURLEncoding.EncodeToString(jti),
+		"nbf": now.Unix(),
+		"iat": now.Unix(),
+		"exp": now.Add(assertionLifetime).Unix(),
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign client assertion: %w", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// loadCertificate reads a PEM certificate and its RSA private key. Both may
+// live in the same file.
+func loadCertificate(certPath, keyPath string) (*x509.Certificate, *rsa.PrivateKey, error) {
+	if ext := strings.ToLower(filepath.Ext(certPath)); ext == ".pfx" || ext == ".p12" {
+		return nil, nil, fmt.Errorf("PFX certificates are not supported; convert to PEM with: openssl pkcs12 -in %s -out cert.pem -nodes", certPath)
+	}
+
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read certificate: %w", err)
+	}
+	certBlock := findPEMBlock(certPEM, "CERTIFICATE")
+	if certBlock == nil {
+		return nil, nil, fmt.Errorf("no PEM certificate found in %s", certPath)
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	if time.Now().After(cert.NotAfter) {
+		return nil, nil, fmt.Errorf("certificate %s expired on %s", certPath, cert.NotAfter.Format("2006-01-02"))
+	}
+
+	keyPEM := certPEM
+	if keyPath != certPath {
+		if keyPEM, err = os.ReadFile(keyPath); err != nil {
+			return nil, nil, fmt.Errorf("failed to read private key: %w", err)
+		}
+	}
+	keyBlock := findPEMBlock(keyPEM, "RSA PRIVATE KEY", "PRIVATE KEY")
+	if keyBlock == nil {
+		return nil, nil, fmt.Errorf("no PEM private key found in %s", keyPath)
+	}
+	key, err := parseRSAKey(keyBlock)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	pub, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok || !pub.Equal(&key.PublicKey) {
+		return nil, nil, fmt.Errorf("private key %s does not match certificate %s", keyPath, certPath)
+	}
+	return cert, key, nil
+}
+
+// findPEMBlock returns the first PEM block of one of the given types
+func findPEMBlock(data []byte, types ...string) *pem.Block {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil
+		}
+		for _, t := range types {
+			if block.Type == t {
+				return block
+			}
+		}
+	}
+}
+
+// parseRSAKey parses a PKCS#1 or PKCS#8 RSA private key
+func parseRSAKey(block *pem.Block) (*rsa.PrivateKey, error) {
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("only RSA keys are supported")
+	}
+	return key, nil
+}
@@ -1,36 +1,323 @@
 package cal
 
 import (
+	"encoding/csv"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/lcorneliussen/md365/internal/auth"
 	"github.com/lcorneliussen/md365/internal/config"
 	"github.com/lcorneliussen/md365/internal/graph"
+	"github.com/lcorneliussen/md365/internal/output"
 	"github.com/lcorneliussen/md365/internal/sync"
 	"gopkg.in/yaml.v3"
 )
 
 // EventInfo represents parsed event information for listing
 type EventInfo struct {
-	Start    time.Time
-	End      time.Time
-	Subject  string
-	Location string
-	Account  string
-	FilePath string
+	ID          string    `json:"id"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	AllDay      bool      `json:"all_day,omitempty"`
+	Subject     string    `json:"subject"`
+	Location    string    `json:"location,omitempty"`
+	Response    string    `json:"response,omitempty"`
+	Sensitivity string    `json:"sensitivity,omitempty"`
+	Organizer   string    `json:"organizer,omitempty"`
+	Attendees   int       `json:"attendee_count,omitempty"`
+	RSVP        RSVP      `json:"rsvp"`
+	Account     string    `json:"account"`
+	FilePath    string    `json:"file"`
+}
+
+// RSVP counts attendee responses as written by sync
+type RSVP struct {
+	Accepted   int `json:"accepted"`
+	Declined   int `json:"declined"`
+	Tentative  int `json:"tentative"`
+	NoResponse int `json:"noresponse"`
+}
+
+// String returns e.g. "3 accepted, 1 declined, 2 no response", skipping zeros
+func (r RSVP) String() string {
+	var parts []string
+	for _, p := range []struct {
+		n     int
+		label string
+	}{
+		{r.Accepted, "accepted"},
+		{r.Tentative, "tentative"},
+		{r.Declined, "declined"},
+		{r.NoResponse, "no response"},
+	} {
+		if p.n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", p.n, p.label))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// parseRSVP reads the rsvp frontmatter map
+func parseRSVP(v interface{}) RSVP {
+	m, _ := v.(map[string]interface{})
+	count := func(key string) int {
+		n, _ := m[key].(int)
+		return n
+	}
+	return RSVP{
+		Accepted:   count("accepted"),
+		Declined:   count("declined"),
+		Tentative:  count("tentative"),
+		NoResponse: count("noresponse"),
+	}
+}
+
+// ListOptions controls which events List shows
+type ListOptions struct {
+	From    time.Time
+	To      time.Time
+	Search  string // Case-insensitive substring (or regex) matched against file content
+	Regex   bool   // Treat Search as a regular expression
+	Account string // Empty or "all" for all accounts
+
+	Status   string // Response filter: accepted, tentative, declined, none or all ("" = all)
+	MineOnly bool   // Hide declined events
+
+	Sensitivity string // normal, personal, private, confidential or all ("" = all)
+
+	GroupByDay     bool // Print a "## date" header per day instead of the date on each line
+	GroupByAccount bool // Section events under a "# account" header per account
+
+	Next int // If > 0, only the next N events from now on, ignoring To
+
+	Limit int // If > 0, print at most this many events
+
+	ShowRSVP bool // Print attendee count and responses below each event
+
+	OnlyFuture bool // Only events starting now or later
+	OnlyPast   bool // Only events that ended before now
+
+	Format string // Go template per event instead of the default line, e.g. {{.Start.Format "15:04"}} {{.Subject}}, or "ics" for an iCalendar feed
+}
+
+// responseStatuses are the valid values for ListOptions.Status
+var responseStatuses = []string{"accepted", "tentative", "declined", "none", "all"}
+
+// sensitivities are the valid values for ListOptions.Sensitivity
+var sensitivities = []string{"normal", "personal", "private", "confidential", "all"}
+
+// normalizeResponse maps a Graph response status to accepted, tentative, declined or none.
+// Events I organize count as accepted.
+func normalizeResponse(response string) string {
+	switch response {
+	case "accepted", "organizer":
+		return "accepted"
+	case "tentativelyAccepted":
+		return "tentative"
+	case "declined":
+		return "declined"
+	default:
+		return "none"
+	}
 }
 
 // List lists calendar events
-func List(cfg *config.Config, fromDate, toDate time.Time, search, account string) error {
+func List(cfg *config.Config, opts ListOptions) error {
+	var format *template.Template
+	if opts.Format != "" && opts.Format != "ics" {
+		var err error
+		if format, err = output.Template(opts.Format, EventInfo{}); err != nil {
+			return err
+		}
+	}
+
+	events, err := collectEvents(cfg, opts)
+	if err != nil {
+		return err
+	}
+
+	events, limitNote := output.Limit(events, opts.Limit)
+	defer limitNote()
+
+	// An iCalendar feed on stdout, e.g. to pipe or serve
+	if opts.Format == "ics" {
+		return exportICS(os.Stdout, events)
+	}
+
+	if opts.GroupByAccount {
+		sort.SliceStable(events, func(i, j int) bool {
+			return events[i].Account < events[j].Account
+		})
+	}
+
+	if output.JSON() {
+		if events == nil {
+			events = []EventInfo{}
+		}
+		return output.PrintJSON(events)
+	}
+
+	// Display events
+	lastDay, lastAccount := "", ""
+	for _, event := range events {
+		if opts.GroupByAccount && event.Account != lastAccount {
+			if lastAccount != "" {
+				fmt.Println()
+			}
+			fmt.Printf("# %s\n", event.Account)
+			lastAccount = event.Account
+			lastDay = ""
+		}
+
+		if opts.GroupByDay {
+			day := event.Start.Format("2006-01-02 Monday")
+			if day != lastDay {
+				if lastDay != "" {
+					fmt.Println()
+				}
+				fmt.Printf("## %s\n", day)
+				lastDay = day
+			}
+		}
+
+		if format != nil {
+			if err := format.Execute(os.Stdout, event); err != nil {
+				return fmt.Errorf("failed to format event: %w", err)
+			}
+			fmt.Println()
+			continue
+		}
+
+		fmt.Println(formatEventLine(event, !opts.GroupByDay))
+		if opts.ShowRSVP && event.Attendees > 0 {
+			fmt.Printf("    👥 %d attendees: %s\n", event.Attendees, event.RSVP)
+		}
+	}
+
+	return nil
+}
+
+// ExportDir writes one calendar-<account>.<format> file per account into
+// dir, creating it if needed. opts.Account limits the export to one account.
+func ExportDir(cfg *config.Config, opts ListOptions, format, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	accounts := cfg.ListAccounts()
+	if opts.Account != "" && opts.Account != "all" {
+		accounts = []string{opts.Account}
+	}
+	for _, acc := range accounts {
+		accOpts := opts
+		accOpts.Account = acc
+		out := filepath.Join(dir, fmt.Sprintf("calendar-%s.%s", acc, format))
+		if err := Export(cfg, accOpts, format, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Export writes the events matching opts in format ("csv" or "ics") to out,
+// or to stdout if out is empty or "-"
+func Export(cfg *config.Config, opts ListOptions, format, out string) error {
+	if format != "csv" && format != "ics" {
+		return fmt.Errorf("invalid export format %q (csv or ics)", format)
+	}
+
+	events, err := collectEvents(cfg, opts)
+	if err != nil {
+		return err
+	}
+
+	w := os.Stdout
+	if out != "" && out != "-" {
+		f, err := os.Create(out)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", out, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if format == "ics" {
+		if err := exportICS(w, events); err != nil {
+			return fmt.Errorf("failed to write iCalendar: %w", err)
+		}
+		if w != os.Stdout {
+			output.Infof("Exported %d events to %s\n", len(events), out)
+		}
+		return nil
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"start", "end", "subject", "location", "account", "organizer"})
+	for _, event := range events {
+		cw.Write([]string{
+			event.Start.Format("2006-01-02 15:04"),
+			event.End.Format("2006-01-02 15:04"),
+			event.Subject,
+			event.Location,
+			event.Account,
+			event.Organizer,
+		})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+
+	if w != os.Stdout {
+		output.Infof("Exported %d events to %s\n", len(events), out)
+	}
+	return nil
+}
+
+// collectEvents reads the local events matching opts, sorted by start time
+func collectEvents(cfg *config.Config, opts ListOptions) ([]EventInfo, error) {
+	fromDate, toDate := opts.From, opts.To
+	if opts.Next > 0 {
+		if now := time.Now(); fromDate.Before(now) {
+			fromDate = now
+		}
+	}
+
+	matches, err := output.Matcher(opts.Search, opts.Regex)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.OnlyFuture && opts.OnlyPast {
+		return nil, fmt.Errorf("--only-future and --only-past cannot be combined")
+	}
+	now := time.Now()
+
+	status := opts.Status
+	if status == "" {
+		status = "all"
+	}
+	if !containsString(responseStatuses, status) {
+		return nil, fmt.Errorf("invalid status '%s'. Valid values: %s", status, strings.Join(responseStatuses, ", "))
+	}
+
+	sensitivity := strings.ToLower(opts.Sensitivity)
+	if sensitivity == "" {
+		sensitivity = "all"
+	}
+	if !containsString(sensitivities, sensitivity) {
+		return nil, fmt.Errorf("invalid sensitivity '%s'. Valid values: %s", opts.Sensitivity, strings.Join(sensitivities, ", "))
+	}
+
 	// Determine which accounts to search
 	var accounts []string
-	if account != "" {
-		accounts = []string{account}
+	if opts.Account != "" && opts.Account != "all" {
+		accounts = []string{opts.Account}
 	} else {
 		accounts = cfg.ListAccounts()
 	}
@@ -56,7 +343,7 @@ func List(cfg *config.Config, fromDate, toDate time.Time, search, account string
 			}
 
 			// Apply search filter
-			if search != "" && !strings.Contains(strings.ToLower(string(data)), strings.ToLower(search)) {
+			if !matches(string(data)) {
 				return nil
 			}
 
@@ -83,31 +370,69 @@ func List(cfg *config.Config, fromDate, toDate time.Time, search, account string
 				return nil
 			}
 
-			// Filter by date range
-			if start.Before(fromDate) || start.After(toDate) {
+			// Filter by date range (--next has no upper bound)
+			if start.Before(fromDate) || (opts.Next == 0 && start.After(toDate)) {
 				return nil
 			}
 
 			endStr, _ := fm["end"].(string)
 			end, _ := time.Parse(time.RFC3339, endStr)
 
+			if opts.OnlyFuture && start.Before(now) {
+				return nil
+			}
+			if opts.OnlyPast && !end.Before(now) {
+				return nil
+			}
+
+			// Filter by response status
+			response, _ := fm["response"].(string)
+			normalized := normalizeResponse(response)
+			if status != "all" && normalized != status {
+				return nil
+			}
+			if opts.MineOnly && normalized == "declined" {
+				return nil
+			}
+
+			// Filter by sensitivity; files without one are normal
+			eventSensitivity, _ := fm["sensitivity"].(string)
+			if eventSensitivity == "" {
+				eventSensitivity = "normal"
+			}
+			if sensitivity != "all" && !strings.EqualFold(eventSensitivity, sensitivity) {
+				return nil
+			}
+
 			subject, _ := fm["subject"].(string)
 			location, _ := fm["location"].(string)
+			organizer, _ := fm["organizer"].(string)
+
+			id, _ := fm["id"].(string)
+			allDay, _ := fm["all_day"].(bool)
+			attendees, _ := fm["attendee_count"].(int)
 
 			events = append(events, EventInfo{
-				Start:    start,
-				End:      end,
-				Subject:  subject,
-				Location: location,
-				Account:  acc,
-				FilePath: path,
+				ID:          id,
+				Start:       start,
+				End:         end,
+				AllDay:      allDay,
+				Subject:     subject,
+				Location:    location,
+				Response:    response,
+				Sensitivity: eventSensitivity,
+				Organizer:   organizer,
+				Attendees:   attendees,
+				RSVP:        parseRSVP(fm["rsvp"]),
+				Account:     acc,
+				FilePath:    path,
 			})
 
 			return nil
 		})
 
 		if err != nil {
-			return fmt.Errorf("failed to walk calendar directory: %w", err)
+			return nil, fmt.Errorf("failed to walk calendar directory: %w", err)
 		}
 	}
 
@@ -116,30 +441,56 @@ func List(cfg *config.Config, fromDate, toDate time.Time, search, account string
 		return events[i].Start.Before(events[j].Start)
 	})
 
-	// Display events
-	for _, event := range events {
-		startDate := event.Start.Format("2006-01-02 Mon")
-		startTime := event.Start.Format("15:04")
-		endTime := event.End.Format("15:04")
+	if opts.Next > 0 && len(events) > opts.Next {
+		events = events[:opts.Next]
+	}
 
-		line := fmt.Sprintf("%s %s-%s %-30s [%s]",
-			startDate, startTime, endTime, truncate(event.Subject, 30), event.Account)
+	return events, nil
+}
 
-		if event.Location != "" {
-			line += fmt.Sprintf(" 📍 %s", event.Location)
-		}
+// formatEventLine formats an event as a single list line, optionally prefixed with its date
+func formatEventLine(event EventInfo, withDate bool) string {
+	startTime := event.Start.Format("15:04")
+	endTime := event.End.Format("15:04")
 
-		fmt.Println(line)
+	line := fmt.Sprintf("%s-%s %-30s [%s]", startTime, endTime, truncate(event.Subject, 30), event.Account)
+	if withDate {
+		line = event.Start.Format("2006-01-02 Mon") + " " + line
 	}
 
-	return nil
+	if event.Location != "" {
+		line += fmt.Sprintf(" 📍 %s", event.Location)
+	}
+
+	// Highlight today's events
+	if y, m, d := time.Now().Date(); event.Start.Year() == y && event.Start.Month() == m && event.Start.Day() == d {
+		line = output.Cyan(line)
+	}
+
+	return line
 }
 
 // parseFlexibleDateTime parses various datetime formats and converts to the configured timezone
 func parseFlexibleDateTime(input, timezoneName string) (string, error) {
-	loc, err := time.LoadLocation(timezoneName)
+	t, err := parseFlexibleTime(input, timezoneName)
+	if err != nil {
+		return "", err
+	}
+
+	// Format without offset for Graph API
+	return formatGraphDateTime(t), nil
+}
+
+// formatGraphDateTime formats a time without offset for the Graph API
+func formatGraphDateTime(t time.Time) string {
+	return t.Format("2006-01-02T15:04:05.0000000")
+}
+
+// parseFlexibleTime parses various datetime formats into a time in the configured timezone
+func parseFlexibleTime(input, timezoneName string) (time.Time, error) {
+	loc, err := sync.LoadLocation(timezoneName)
 	if err != nil {
-		return "", fmt.Errorf("failed to load timezone %s: %w", timezoneName, err)
+		return time.Time{}, fmt.Errorf("failed to load timezone %s: %w", timezoneName, err)
 	}
 
 	// Try parsing various formats
@@ -159,47 +510,150 @@ func parseFlexibleDateTime(input, timezoneName string) (string, error) {
 	}
 
 	if parsed.IsZero() {
-		return "", fmt.Errorf("unable to parse datetime: %s", input)
+		return time.Time{}, fmt.Errorf("unable to parse datetime: %s", input)
 	}
 
 	// Convert to configured timezone
-	inZone := parsed.In(loc)
+	return parsed.In(loc), nil
+}
 
-	// Format without offset for Graph API
-	return inZone.Format("2006-01-02T15:04:05.0000000"), nil
+// allDayRange returns the midnight-aligned start and exclusive end of an
+// all-day event. Inputs are dates (YYYY-MM-DD) or datetimes, which are
+// coerced to whole days; an empty end means a single day.
+func allDayRange(startInput, endInput, timezoneName string) (time.Time, time.Time, error) {
+	start, err := parseDayOrTime(startInput, timezoneName)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid start date: %w", err)
+	}
+	start = midnight(start)
+
+	if endInput == "" {
+		return start, start.AddDate(0, 0, 1), nil
+	}
+	end, err := parseDayOrTime(endInput, timezoneName)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid end date: %w", err)
+	}
+	// An end within a day covers that whole day
+	if !end.Equal(midnight(end)) {
+		end = midnight(end).AddDate(0, 0, 1)
+	}
+	if !end.After(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("end date must be after the start date (the end of an all-day event is exclusive)")
+	}
+	return start, end, nil
+}
+
+// parseDayOrTime parses a date (YYYY-MM-DD) or any datetime accepted by
+// parseFlexibleTime in the configured timezone
+func parseDayOrTime(input, timezoneName string) (time.Time, error) {
+	loc, err := sync.LoadLocation(timezoneName)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to load timezone %s: %w", timezoneName, err)
+	}
+	if t, err := time.ParseInLocation("2006-01-02", input, loc); err == nil {
+		return t, nil
+	}
+	return parseFlexibleTime(input, timezoneName)
+}
+
+// midnight returns the start of the day of t in its location
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// Attendee notification modes for Create and Delete
+const (
+	NotifyAll  = "all"  // Send invitations/cancellations (default)
+	NotifyNone = "none" // Refuse operations that would notify attendees
+)
+
+// CreateOptions describes the event to create
+type CreateOptions struct {
+	Subject    string
+	Start      string
+	End        string
+	Location   string
+	Body       string
+	BodyType   string // "text" (default) or "html"
+	Attendees  []string
+	Recurrence string // e.g. "weekly:MO,WE;count=10"; empty for a single event
+	Force      bool   // Bypass cross-tenant checks
+	Notify     string // NotifyAll or NotifyNone
+	DedupeKey  string // Sent as transactionId so Graph ignores a repeated create
+	AllDay     bool   // Whole days: Start and End are dates, End exclusive (default: one day)
+}
+
+// checkNotify validates a notify mode. Graph has no switch to suppress
+// meeting notifications, so "none" is enforced by refusing to notify.
+func checkNotify(notify string) error {
+	switch notify {
+	case "", NotifyAll, NotifyNone:
+		return nil
+	}
+	return fmt.Errorf("invalid notify mode %q (use all or none; Graph cannot limit notifications to external attendees)", notify)
 }
 
 // Create creates a new calendar event
-func Create(cfg *config.Config, account, subject, start, end, location, body string, attendees []string, force bool) error {
+func Create(cfg *config.Config, account string, opts CreateOptions) error {
+	if err := checkNotify(opts.Notify); err != nil {
+		return err
+	}
+
+	// Validate attendees before the cross-tenant check and any API call
+	recipients := make([]graph.EmailAddress, len(opts.Attendees))
+	attendees := make([]string, len(opts.Attendees))
+	for i, a := range opts.Attendees {
+		recipient, err := graph.ParseRecipient(a)
+		if err != nil {
+			return err
+		}
+		recipients[i] = recipient
+		attendees[i] = recipient.Address
+	}
+
+	if opts.Notify == NotifyNone && len(attendees) > 0 {
+		return fmt.Errorf("attendees always receive an invitation when the event is created; drop the attendees or use --notify all")
+	}
+
 	// Check cross-tenant unless force is enabled
-	if !force && len(attendees) > 0 {
+	if !opts.Force && len(attendees) > 0 {
 		if err := cfg.CheckCrossTenant(account, attendees); err != nil {
 			return err
 		}
 	}
 
-	// Get access token
-	token, err := auth.GetAccessToken(cfg, account)
+	// Get Graph client
+	client, err := auth.NewGraphClient(cfg, account)
 	if err != nil {
 		return err
 	}
 
 	// Parse and convert datetimes to configured timezone
-	startDateTime, err := parseFlexibleDateTime(start, cfg.Timezone)
-	if err != nil {
-		return fmt.Errorf("invalid start datetime: %w", err)
-	}
+	var start time.Time
+	var startDateTime, endDateTime string
+	if opts.AllDay {
+		var end time.Time
+		if start, end, err = allDayRange(opts.Start, opts.End, cfg.Timezone); err != nil {
+			return err
+		}
+		startDateTime, endDateTime = formatGraphDateTime(start), formatGraphDateTime(end)
+	} else {
+		start, err = parseFlexibleTime(opts.Start, cfg.Timezone)
+		if err != nil {
+			return fmt.Errorf("invalid start datetime: %w", err)
+		}
+		startDateTime = formatGraphDateTime(start)
 
-	endDateTime, err := parseFlexibleDateTime(end, cfg.Timezone)
-	if err != nil {
-		return fmt.Errorf("invalid end datetime: %w", err)
+		endDateTime, err = parseFlexibleDateTime(opts.End, cfg.Timezone)
+		if err != nil {
+			return fmt.Errorf("invalid end datetime: %w", err)
+		}
 	}
 
 	// Create event
-	client := graph.NewClient(token)
-
 	event := &graph.Event{
-		Subject: subject,
+		Subject: opts.Subject,
 		Start: graph.DateTime{
 			DateTime: startDateTime,
 			TimeZone: cfg.Timezone,
@@ -208,91 +662,312 @@ func Create(cfg *config.Config, account, subject, start, end, location, body str
 			DateTime: endDateTime,
 			TimeZone: cfg.Timezone,
 		},
+		IsAllDay: opts.AllDay,
 	}
 
-	if location != "" {
-		event.Location = &graph.Location{DisplayName: location}
+	if opts.Location != "" {
+		event.Location = &graph.Location{DisplayName: opts.Location}
 	}
 
-	if body != "" {
+	if opts.Recurrence != "" {
+		event.Recurrence, err = parseRecurrence(opts.Recurrence, start, cfg.Timezone)
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.Body != "" {
+		contentType := "text"
+		if strings.EqualFold(opts.BodyType, "html") {
+			contentType = "html"
+		}
 		event.Body = &graph.Body{
-			ContentType: "text",
-			Content:     body,
+			ContentType: contentType,
+			Content:     opts.Body,
 		}
 	}
 
 	// Add attendees
-	if len(attendees) > 0 {
-		event.Attendees = make([]graph.Attendee, len(attendees))
-		for i, email := range attendees {
-			event.Attendees[i] = graph.Attendee{
-				EmailAddress: graph.EmailAddress{
-					Address: email,
-				},
-			}
+	if len(recipients) > 0 {
+		event.Attendees = make([]graph.Attendee, len(recipients))
+		for i, recipient := range recipients {
+			event.Attendees[i] = graph.Attendee{EmailAddress: recipient}
 		}
 	}
 
+	event.TransactionID = opts.DedupeKey
+	requested := time.Now()
+
 	created, err := client.CreateEvent(event)
 	if err != nil {
+		if event.Recurrence != nil {
+			return fmt.Errorf("failed to create recurring event (%s): %w", opts.Recurrence, err)
+		}
 		return err
 	}
 
+	// A retry with the same dedupe key gets the existing event back; it is
+	// already on disk if the earlier create (or a sync) wrote it
+	duplicate := opts.DedupeKey != "" && sync.FindEventFile(cfg, account, created.ID) != ""
+
 	// Write to local file
 	filePath, err := sync.WriteEventFile(cfg, account, created, cfg.Timezone)
 	if err != nil {
 		return fmt.Errorf("event created but failed to write local file: %w", err)
 	}
 
-	fmt.Printf("Event created: %s\n", filePath)
+	if duplicate || isDuplicate(created, requested) {
+		output.Infof("Duplicate prevented: an event with dedupe key '%s' already exists: %s\n", opts.DedupeKey, filePath)
+		return nil
+	}
+
+	output.Infof("Event created: %s\n", filePath)
 	return nil
 }
 
-// Delete deletes a calendar event
-func Delete(cfg *config.Config, account, id, filePath string) error {
-	// If file provided, extract account and ID
+// isDuplicate reports whether Graph answered a create carrying a
+// transactionId with an event created before the request, i.e. one that
+// already existed. Allows for clock skew between here and Graph, so it only
+// catches events without a local file that are older than that.
+func isDuplicate(created *graph.Event, requested time.Time) bool {
+	if created.TransactionID == "" || created.CreatedDateTime == "" {
+		return false
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, created.CreatedDateTime)
+	if err != nil {
+		return false
+	}
+	return createdAt.Before(requested.Add(-auth.MaxClockSkew))
+}
+
+// resolveEvent returns the account and event ID, reading them from the
+// frontmatter of filePath if a file is given
+func resolveEvent(account, id, filePath string) (string, string, error) {
 	if filePath != "" {
 		data, err := os.ReadFile(filePath)
 		if err != nil {
-			return fmt.Errorf("failed to read file: %w", err)
+			return "", "", fmt.Errorf("failed to read file: %w", err)
 		}
 
 		content := string(data)
 		parts := strings.SplitN(content, "---", 3)
 		if len(parts) < 3 {
-			return fmt.Errorf("invalid frontmatter in file")
+			return "", "", fmt.Errorf("invalid frontmatter in file")
 		}
 
 		var fm map[string]interface{}
 		if err := yaml.Unmarshal([]byte(parts[1]), &fm); err != nil {
-			return fmt.Errorf("failed to parse frontmatter: %w", err)
+			return "", "", fmt.Errorf("failed to parse frontmatter: %w", err)
 		}
 
 		var ok bool
 		account, ok = fm["account"].(string)
 		if !ok {
-			return fmt.Errorf("account not found in frontmatter")
+			return "", "", fmt.Errorf("account not found in frontmatter")
 		}
 
 		id, ok = fm["id"].(string)
 		if !ok {
-			return fmt.Errorf("id not found in frontmatter")
+			return "", "", fmt.Errorf("id not found in frontmatter")
 		}
 	}
 
 	if account == "" || id == "" {
-		return fmt.Errorf("account and id are required")
+		return "", "", fmt.Errorf("account and id are required")
+	}
+
+	return account, id, nil
+}
+
+// Move reschedules a calendar event to a new start and end (or start + duration).
+// Moving a meeting you organize always sends attendees an update; with
+// notify "none" such meetings are refused instead.
+func Move(cfg *config.Config, account, id, filePath, start, end string, duration time.Duration, notify string) error {
+	if err := checkNotify(notify); err != nil {
+		return err
+	}
+
+	account, id, err := resolveEvent(account, id, filePath)
+	if err != nil {
+		return err
+	}
+
+	startTime, err := parseFlexibleTime(start, cfg.Timezone)
+	if err != nil {
+		return fmt.Errorf("invalid start datetime: %w", err)
+	}
+
+	var endTime time.Time
+	switch {
+	case end != "":
+		endTime, err = parseFlexibleTime(end, cfg.Timezone)
+		if err != nil {
+			return fmt.Errorf("invalid end datetime: %w", err)
+		}
+	case duration > 0:
+		endTime = startTime.Add(duration)
+	default:
+		return fmt.Errorf("either end or duration is required")
+	}
+
+	if !endTime.After(startTime) {
+		return fmt.Errorf("end must be after start")
+	}
+
+	// Get Graph client
+	client, err := auth.NewGraphClient(cfg, account)
+	if err != nil {
+		return err
+	}
+
+	if notify == NotifyNone {
+		event, err := client.GetEvent(id)
+		if err != nil {
+			return err
+		}
+		if event.IsOrganizer && len(event.Attendees) > 0 {
+			return fmt.Errorf("moving a meeting you organize notifies its %d attendee(s); use --notify all to send the update", len(event.Attendees))
+		}
+	}
+
+	updated, err := client.UpdateEvent(id, map[string]interface{}{
+		"start": graph.DateTime{
+			DateTime: formatGraphDateTime(startTime),
+			TimeZone: cfg.Timezone,
+		},
+		"end": graph.DateTime{
+			DateTime: formatGraphDateTime(endTime),
+			TimeZone: cfg.Timezone,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Rewrite local file (renamed if the date changed)
+	newPath, err := sync.WriteEventFile(cfg, account, updated, cfg.Timezone)
+	if err != nil {
+		return fmt.Errorf("event moved but failed to write local file: %w", err)
+	}
+
+	output.Infof("Event moved: %s\n", newPath)
+	return nil
+}
+
+// CreateFromFile creates a calendar event from a markdown file with
+// subject/start/end/location/attendees in the frontmatter and the body below.
+// The account flag takes precedence over an account in the frontmatter.
+// Only Force, Notify and DedupeKey are used from opts.
+func CreateFromFile(cfg *config.Config, account, filePath string, opts CreateOptions) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	content := string(data)
+	parts := strings.SplitN(content, "---", 3)
+	if len(parts) < 3 {
+		return fmt.Errorf("invalid frontmatter in file")
+	}
+
+	var fm map[string]interface{}
+	if err := yaml.Unmarshal([]byte(parts[1]), &fm); err != nil {
+		return fmt.Errorf("failed to parse frontmatter: %w", err)
+	}
+
+	if account == "" {
+		account, _ = fm["account"].(string)
+	}
+	if account == "" {
+		return fmt.Errorf("account is required (--account or 'account' in frontmatter)")
+	}
+
+	opts.Subject, _ = fm["subject"].(string)
+	opts.Start = frontmatterTime(fm["start"])
+	opts.End = frontmatterTime(fm["end"])
+	opts.Location, _ = fm["location"].(string)
+	opts.BodyType, _ = fm["body_type"].(string)
+	if opts.Subject == "" || opts.Start == "" || opts.End == "" {
+		return fmt.Errorf("subject, start and end are required in frontmatter")
+	}
+
+	// Attendees are "Name <email>" strings or {name, email} maps as written by sync
+	var attendees []string
+	if list, ok := fm["attendees"].([]interface{}); ok {
+		for _, a := range list {
+			switch a := a.(type) {
+			case string:
+				attendees = append(attendees, a)
+			case map[string]interface{}:
+				if email, ok := a["email"].(string); ok && email != "" {
+					attendees = append(attendees, graph.EmailAddress{Address: email}.Format())
+				}
+			}
+		}
+	}
+
+	// Body is everything after the frontmatter, minus a leading "# Subject" heading
+	body := strings.TrimSpace(parts[2])
+	if strings.HasPrefix(body, "# ") {
+		if i := strings.Index(body, "\n"); i >= 0 {
+			body = strings.TrimSpace(body[i+1:])
+		} else {
+			body = ""
+		}
+	}
+
+	opts.Attendees = attendees
+	opts.Body = body
+
+	return Create(cfg, account, opts)
+}
+
+// frontmatterTime returns a start/end frontmatter value as a string. YAML
+// decodes unquoted timestamps as time.Time.
+func frontmatterTime(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case time.Time:
+		return t.Format(time.RFC3339)
+	}
+	return ""
+}
+
+// Delete deletes a calendar event.
+// Deleting a meeting you organize always notifies its attendees; with
+// NotifyAll it is cancelled with the given comment, with NotifyNone the
+// delete is refused.
+func Delete(cfg *config.Config, account, id, filePath, notify, comment string) error {
+	if err := checkNotify(notify); err != nil {
+		return err
+	}
+
+	// If file provided, extract account and ID
+	account, id, err := resolveEvent(account, id, filePath)
+	if err != nil {
+		return err
+	}
+
+	// Get Graph client
+	client, err := auth.NewGraphClient(cfg, account)
+	if err != nil {
+		return err
 	}
 
-	// Get access token
-	token, err := auth.GetAccessToken(cfg, account)
+	event, err := client.GetEvent(id)
 	if err != nil {
 		return err
 	}
 
-	// Delete via API
-	client := graph.NewClient(token)
-	if err := client.DeleteEvent(id); err != nil {
+	if event.IsOrganizer && len(event.Attendees) > 0 {
+		if notify == NotifyNone {
+			return fmt.Errorf("deleting a meeting you organize notifies its %d attendee(s); use --notify all to cancel it", len(event.Attendees))
+		}
+		if err := client.CancelEvent(id, comment); err != nil {
+			return err
+		}
+	} else if err := client.DeleteEvent(id); err != nil {
 		return err
 	}
 
@@ -301,7 +976,7 @@ func Delete(cfg *config.Config, account, id, filePath string) error {
 		if err := os.Remove(filePath); err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: failed to delete local file: %v\n", err)
 		}
-		fmt.Printf("Event deleted: %s\n", filePath)
+		output.Infof("Event deleted: %s\n", filePath)
 	} else {
 		// Find and delete file by ID
 		calDir := filepath.Join(cfg.DataDir, account, "calendar")
@@ -331,7 +1006,7 @@ func Delete(cfg *config.Config, account, id, filePath string) error {
 			fileID, ok := fm["id"].(string)
 			if ok && fileID == id {
 				if err := os.Remove(path); err == nil {
-					fmt.Printf("Event deleted: %s\n", path)
+					output.Infof("Event deleted: %s\n", path)
 					deleted = true
 				}
 			}
@@ -340,17 +1015,206 @@ func Delete(cfg *config.Config, account, id, filePath string) error {
 		})
 
 		if !deleted {
-			fmt.Println("Event deleted (local file not found)")
+			output.Infof("Event deleted (local file not found)\n")
 		}
 	}
 
 	return nil
 }
 
-// truncate truncates a string to a maximum length
+// Matching returns the local events selected by opts, sorted by start time,
+// using the same filters as List
+func Matching(cfg *config.Config, opts ListOptions) ([]EventInfo, error) {
+	return collectEvents(cfg, opts)
+}
+
+// DeleteEvents deletes events via the API and removes their local files.
+// Meetings you organize (or whose role is unknown locally) go through Delete
+// one by one, so they are cancelled and notify applies; all other events are
+// deleted in $batch calls. It keeps going after a failure and returns an
+// error counting the failures.
+func DeleteEvents(cfg *config.Config, events []EventInfo, notify, comment string) error {
+	if err := checkNotify(notify); err != nil {
+		return err
+	}
+
+	failed := 0
+	batches := make(map[string][]EventInfo) // account -> events
+	var accounts []string
+	for _, event := range events {
+		if event.Response == "organizer" || event.Response == "" || event.ID == "" {
+			if err := Delete(cfg, event.Account, "", event.FilePath, notify, comment); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to delete %s: %v\n", event.FilePath, err)
+				failed++
+			}
+			continue
+		}
+		if _, ok := batches[event.Account]; !ok {
+			accounts = append(accounts, event.Account)
+		}
+		batches[event.Account] = append(batches[event.Account], event)
+	}
+
+	for _, account := range accounts {
+		batch := batches[account]
+		client, err := auth.NewGraphClient(cfg, account)
+		if err != nil {
+			return err
+		}
+
+		ids := make([]string, len(batch))
+		for i, event := range batch {
+			ids[i] = event.ID
+		}
+		errs, err := client.DeleteEvents(ids)
+		if err != nil {
+			return fmt.Errorf("failed to delete events of '%s': %w", account, err)
+		}
+
+		for _, event := range batch {
+			if err := errs[event.ID]; err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to delete %s: %v\n", event.FilePath, err)
+				failed++
+				continue
+			}
+			if err := os.Remove(event.FilePath); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to delete local file: %v\n", err)
+			}
+			output.Infof("Event deleted: %s\n", event.FilePath)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to delete %d of %d event(s)", failed, len(events))
+	}
+	return nil
+}
+
+// BusyBlock represents a busy period of an attendee for free/busy output
+type BusyBlock struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Status  string    `json:"status"`
+	Subject string    `json:"subject,omitempty"`
+}
+
+// AttendeeSchedule represents the busy blocks of one attendee
+type AttendeeSchedule struct {
+	Email string      `json:"email"`
+	Busy  []BusyBlock `json:"busy"`
+	Error string      `json:"error,omitempty"`
+}
+
+// FreeBusy prints the busy blocks of each attendee in the given range
+func FreeBusy(cfg *config.Config, account string, attendees []string, fromDate, toDate time.Time, intervalMinutes int) error {
+	if len(attendees) == 0 {
+		return fmt.Errorf("at least one attendee is required")
+	}
+
+	emails := make([]string, len(attendees))
+	for i, a := range attendees {
+		recipient, err := graph.ParseRecipient(a)
+		if err != nil {
+			return err
+		}
+		emails[i] = recipient.Address
+	}
+	attendees = emails
+
+	loc, err := sync.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return fmt.Errorf("failed to load timezone %s: %w", cfg.Timezone, err)
+	}
+
+	// Get Graph client
+	client, err := auth.NewGraphClient(cfg, account)
+	if err != nil {
+		return err
+	}
+
+	schedules, err := client.GetSchedule(attendees, fromDate.In(loc), toDate.In(loc), intervalMinutes)
+	if err != nil {
+		return err
+	}
+
+	results := make([]AttendeeSchedule, 0, len(schedules))
+	for _, schedule := range schedules {
+		result := AttendeeSchedule{
+			Email: schedule.ScheduleID,
+			Busy:  []BusyBlock{},
+		}
+		if schedule.Error != nil {
+			result.Error = schedule.Error.Message
+		}
+
+		for _, item := range schedule.ScheduleItems {
+			if item.Status == "free" {
+				continue
+			}
+			start, err := sync.ParseGraphTime(item.Start, cfg.Timezone)
+			if err != nil {
+				continue
+			}
+			end, err := sync.ParseGraphTime(item.End, cfg.Timezone)
+			if err != nil {
+				continue
+			}
+			result.Busy = append(result.Busy, BusyBlock{
+				Start:   start,
+				End:     end,
+				Status:  item.Status,
+				Subject: item.Subject,
+			})
+		}
+
+		sort.Slice(result.Busy, func(i, j int) bool {
+			return result.Busy[i].Start.Before(result.Busy[j].Start)
+		})
+		results = append(results, result)
+	}
+
+	if output.JSON() {
+		return output.PrintJSON(results)
+	}
+
+	for _, result := range results {
+		fmt.Printf("%s:\n", result.Email)
+		if result.Error != "" {
+			fmt.Printf("  error: %s\n", result.Error)
+			continue
+		}
+		if len(result.Busy) == 0 {
+			fmt.Println("  free")
+			continue
+		}
+		for _, block := range result.Busy {
+			line := fmt.Sprintf("  %s %s-%s %-10s",
+				block.Start.Format("2006-01-02 Mon"), block.Start.Format("15:04"), block.End.Format("15:04"), block.Status)
+			if block.Subject != "" {
+				line += " " + block.Subject
+			}
+			fmt.Println(line)
+		}
+	}
+
+	return nil
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// truncate truncates a string to a maximum number of characters
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
+	runes := []rune(s)
+	if len(runes) <= maxLen {
 		return s
 	}
-	return s[:maxLen]
+	return string(runes[:maxLen])
 }
@@ -0,0 +1,50 @@
+package cal
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     string
+		maxLen int
+		want   string
+	}{
+		{"short ascii", "Standup", 30, "Standup"},
+		{"ascii cut", "Quarterly planning", 9, "Quarterly"},
+		{"accents within limit", "Réunion d'équipe", 16, "Réunion d'équipe"},
+		{"cut after accent", "Café meeting", 4, "Café"},
+		{"cut before accent", "Café meeting", 3, "Caf"},
+		{"emoji at limit", "🎉 Party", 1, "🎉"},
+		{"emoji around limit", "Launch 🚀🚀 day", 8, "Launch 🚀"},
+		{"combining marks count as runes", "e\u0301e\u0301e\u0301", 3, "e\u0301e"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncate(tt.in, tt.maxLen)
+			if got != tt.want {
+				t.Errorf("truncate(%q, %d) = %q, want %q", tt.in, tt.maxLen, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("truncate(%q, %d) = %q is not valid UTF-8", tt.in, tt.maxLen, got)
+			}
+			if n := utf8.RuneCountInString(got); n > tt.maxLen {
+				t.Errorf("truncate(%q, %d) has %d runes", tt.in, tt.maxLen, n)
+			}
+		})
+	}
+}
+
+// Every cut of a multi-byte subject stays valid UTF-8 within the limit
+func TestTruncateBoundaries(t *testing.T) {
+	subject := "Ünïcødé 🎉 résumé ✓"
+	for maxLen := 0; maxLen <= utf8.RuneCountInString(subject)+1; maxLen++ {
+		got := truncate(subject, maxLen)
+		if !utf8.ValidString(got) || utf8.RuneCountInString(got) > maxLen {
+			t.Errorf("truncate(%q, %d) = %q", subject, maxLen, got)
+		}
+	}
+}
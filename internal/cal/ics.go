@@ -0,0 +1,397 @@
+package cal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lcorneliussen/md365/internal/auth"
+	"github.com/lcorneliussen/md365/internal/config"
+	"github.com/lcorneliussen/md365/internal/graph"
+	"github.com/lcorneliussen/md365/internal/output"
+	"github.com/lcorneliussen/md365/internal/sync"
+)
+
+// icsProperty is a single content line, e.g. DTSTART;TZID=Europe/Berlin:20260301T100000
+type icsProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// Import creates calendar events from the VEVENT blocks of an .ics file.
+// Attendees of an invite organized by someone else are dropped unless
+// keepAttendees is set, since the new event would re-invite all of them.
+// Each event carries a transactionId derived from its UID, so importing the
+// same file again does not create duplicates.
+func Import(cfg *config.Config, account, filePath string, force bool, notify string, keepAttendees bool) error {
+	if err := checkNotify(notify); err != nil {
+		return err
+	}
+
+	acc, err := cfg.GetAccount(account)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	loc, err := sync.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return fmt.Errorf("failed to load timezone %s: %w", cfg.Timezone, err)
+	}
+
+	var events []*graph.Event
+	for i, block := range parseICS(string(data)) {
+		event, organizer, err := icsToEvent(block, loc)
+		if err != nil {
+			return fmt.Errorf("event %d: %w", i+1, err)
+		}
+		if len(event.Attendees) > 0 && !keepAttendees && isForeignOrganizer(organizer, acc) {
+			fmt.Fprintf(os.Stderr, "Warning: %q is organized by %s; importing without its %d attendee(s) (use --keep-attendees to invite them)\n",
+				event.Subject, organizer, len(event.Attendees))
+			event.Attendees = nil
+		}
+		events = append(events, event)
+	}
+	if len(events) == 0 {
+		return fmt.Errorf("no events found in %s", filePath)
+	}
+
+	// Check all events before creating any
+	for _, event := range events {
+		var attendees []string
+		for i, a := range event.Attendees {
+			recipient, err := graph.ParseRecipient(a.EmailAddress.Address)
+			if err != nil {
+				return fmt.Errorf("%q: %w", event.Subject, err)
+			}
+			event.Attendees[i].EmailAddress.Address = recipient.Address
+			attendees = append(attendees, recipient.Address)
+		}
+		if len(attendees) == 0 {
+			continue
+		}
+		if notify == NotifyNone {
+			return fmt.Errorf("%q has attendees, who always receive an invitation; use --notify all", event.Subject)
+		}
+		if !force {
+			if err := cfg.CheckCrossTenant(account, attendees); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Get Graph client
+	client, err := auth.NewGraphClient(cfg, account)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, event := range events {
+		requested := time.Now()
+		created, err := client.CreateEvent(event)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to create %q: %v\n", event.Subject, err)
+			failed++
+			continue
+		}
+
+		// Graph returns the existing event for a known transactionId
+		duplicate := event.TransactionID != "" && sync.FindEventFile(cfg, account, created.ID) != ""
+
+		filePath, err := sync.WriteEventFile(cfg, account, created, cfg.Timezone)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %q created but failed to write local file: %v\n", event.Subject, err)
+			continue
+		}
+
+		if duplicate || isDuplicate(created, requested) {
+			output.Infof("Already imported: %s\n", filePath)
+			continue
+		}
+		output.Infof("Event created: %s\n", filePath)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to import %d of %d events", failed, len(events))
+	}
+	return nil
+}
+
+// parseICS returns the properties of each VEVENT in an iCalendar document
+func parseICS(data string) [][]icsProperty {
+	// Unfold continuation lines (CRLF followed by a space or tab)
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	data = strings.ReplaceAll(data, "\n ", "")
+	data = strings.ReplaceAll(data, "\n\t", "")
+
+	var events [][]icsProperty
+	var current []icsProperty
+	depth := 0 // Nesting inside a VEVENT, e.g. VALARM
+
+	for _, line := range strings.Split(data, "\n") {
+		prop, ok := parseICSLine(line)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case prop.Name == "BEGIN" && strings.EqualFold(prop.Value, "VEVENT"):
+			current = []icsProperty{}
+			depth = 1
+		case prop.Name == "BEGIN" && depth > 0:
+			depth++
+		case prop.Name == "END" && depth > 1:
+			depth--
+		case prop.Name == "END" && depth == 1 && strings.EqualFold(prop.Value, "VEVENT"):
+			events = append(events, current)
+			current = nil
+			depth = 0
+		case depth == 1:
+			current = append(current, prop)
+		}
+	}
+
+	return events
+}
+
+// parseICSLine splits a content line into name, parameters and value
+func parseICSLine(line string) (icsProperty, bool) {
+	line = strings.TrimRight(line, "\r")
+
+	// The value starts at the first colon outside a quoted parameter
+	colon := -1
+	quoted := false
+	for i, r := range line {
+		if r == '"' {
+			quoted = !quoted
+		} else if r == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return icsProperty{}, false
+	}
+
+	fields := strings.Split(line[:colon], ";")
+	prop := icsProperty{
+		Name:   strings.ToUpper(fields[0]),
+		Params: make(map[string]string),
+		Value:  line[colon+1:],
+	}
+	for _, param := range fields[1:] {
+		if k, v, ok := strings.Cut(param, "="); ok {
+			prop.Params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+
+	return prop, true
+}
+
+// isForeignOrganizer reports whether an ORGANIZER email is someone other
+// than the account, as far as its hint and user_id tell
+func isForeignOrganizer(organizer string, acc *config.Account) bool {
+	if organizer == "" {
+		return false
+	}
+	return !strings.EqualFold(organizer, acc.Hint) && !strings.EqualFold(organizer, acc.UserID)
+}
+
+// icsTransactionID derives a Graph transactionId from a VEVENT's UID (and
+// RECURRENCE-ID, which distinguishes overridden occurrences)
+func icsTransactionID(uid, recurrenceID string) string {
+	if uid == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(uid + "\x00" + recurrenceID))
+	return "md365-ics-" + hex.EncodeToString(sum[:16])
+}
+
+// mailtoAddress strips a mailto: prefix from a CAL-ADDRESS value
+func mailtoAddress(value string) string {
+	if i := strings.Index(strings.ToLower(value), "mailto:"); i >= 0 {
+		return value[i+len("mailto:"):]
+	}
+	return value
+}
+
+// icsToEvent converts VEVENT properties to a Graph event in the given
+// location and returns the ORGANIZER email, if any
+func icsToEvent(props []icsProperty, loc *time.Location) (*graph.Event, string, error) {
+	event := &graph.Event{}
+	var start, end time.Time
+	var hasEnd bool
+	var organizer, uid, recurrenceID string
+	var err error
+
+	for _, prop := range props {
+		switch prop.Name {
+		case "SUMMARY":
+			event.Subject = icsUnescape(prop.Value)
+		case "LOCATION":
+			if location := icsUnescape(prop.Value); location != "" {
+				event.Location = &graph.Location{DisplayName: location}
+			}
+		case "DESCRIPTION":
+			if body := icsUnescape(prop.Value); body != "" {
+				event.Body = &graph.Body{ContentType: "text", Content: body}
+			}
+		case "DTSTART":
+			start, event.IsAllDay, err = parseICSTime(prop, loc)
+			if err != nil {
+				return nil, "", fmt.Errorf("invalid DTSTART: %w", err)
+			}
+		case "DTEND":
+			end, _, err = parseICSTime(prop, loc)
+			if err != nil {
+				return nil, "", fmt.Errorf("invalid DTEND: %w", err)
+			}
+			hasEnd = true
+		case "ATTENDEE":
+			event.Attendees = append(event.Attendees, graph.Attendee{
+				EmailAddress: graph.EmailAddress{
+					Name:    prop.Params["CN"],
+					Address: mailtoAddress(prop.Value),
+				},
+			})
+		case "ORGANIZER":
+			organizer = mailtoAddress(prop.Value)
+		case "UID":
+			uid = prop.Value
+		case "RECURRENCE-ID":
+			recurrenceID = prop.Value
+		}
+	}
+
+	if start.IsZero() {
+		return nil, "", fmt.Errorf("missing DTSTART")
+	}
+
+	// Without DTEND, all-day events last one day and timed events end at start
+	if !hasEnd {
+		end = start
+		if event.IsAllDay {
+			end = start.AddDate(0, 0, 1)
+		}
+	}
+
+	event.Start = graph.DateTime{DateTime: formatGraphDateTime(start), TimeZone: loc.String()}
+	event.End = graph.DateTime{DateTime: formatGraphDateTime(end), TimeZone: loc.String()}
+	event.TransactionID = icsTransactionID(uid, recurrenceID)
+
+	return event, organizer, nil
+}
+
+// parseICSTime parses a DTSTART/DTEND value into the given location and
+// reports whether it is a date-only (all-day) value
+func parseICSTime(prop icsProperty, loc *time.Location) (time.Time, bool, error) {
+	value := prop.Value
+
+	if strings.EqualFold(prop.Params["VALUE"], "DATE") || len(value) == len("20060102") {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	}
+
+	// UTC
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t.In(loc), false, err
+	}
+
+	// Explicit zone; Windows zone names can't be loaded and fall back to loc
+	src := loc
+	if tzid := prop.Params["TZID"]; tzid != "" {
+		if l, err := sync.LoadLocation(tzid); err == nil {
+			src = l
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: unknown time zone %s, assuming %s\n", tzid, loc)
+		}
+	}
+
+	t, err := time.ParseInLocation("20060102T150405", value, src)
+	return t.In(loc), false, err
+}
+
+// icsUnescape decodes TEXT escapes (\n, \, \; \\)
+func icsUnescape(s string) string {
+	return strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(s)
+}
+
+// icsEscape encodes TEXT values (RFC 5545 3.3.11)
+func icsEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// icsFold splits a content line into lines of at most 75 octets, without
+// splitting UTF-8 characters, continued by a leading space
+func icsFold(line string) string {
+	var b strings.Builder
+	for len(line) > 75 {
+		n := 75
+		for n > 0 && !utf8.RuneStart(line[n]) {
+			n--
+		}
+		b.WriteString(line[:n])
+		b.WriteString("\r\n ")
+		line = line[n:]
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+// exportICS writes events as a VCALENDAR to w
+func exportICS(w io.Writer, events []EventInfo) error {
+	var b strings.Builder
+	b.WriteString("BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//md365//md365//EN\r\nCALSCALE:GREGORIAN\r\n")
+
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	for _, event := range events {
+		b.WriteString("BEGIN:VEVENT\r\n")
+		uid := event.ID
+		if uid == "" {
+			uid = event.FilePath
+		}
+		b.WriteString(icsFold("UID:" + icsEscape(uid)))
+		b.WriteString("DTSTAMP:" + stamp + "\r\n")
+		if event.AllDay {
+			b.WriteString("DTSTART;VALUE=DATE:" + event.Start.Format("20060102") + "\r\n")
+			b.WriteString("DTEND;VALUE=DATE:" + event.End.Format("20060102") + "\r\n")
+		} else {
+			b.WriteString("DTSTART:" + event.Start.UTC().Format("20060102T150405Z") + "\r\n")
+			if !event.End.IsZero() {
+				b.WriteString("DTEND:" + event.End.UTC().Format("20060102T150405Z") + "\r\n")
+			}
+		}
+		b.WriteString(icsFold("SUMMARY:" + icsEscape(event.Subject)))
+		if event.Location != "" {
+			b.WriteString(icsFold("LOCATION:" + icsEscape(event.Location)))
+		}
+		if organizer, err := graph.ParseRecipient(event.Organizer); err == nil {
+			line := "ORGANIZER"
+			if organizer.Name != "" {
+				line += ";CN=\"" + strings.ReplaceAll(organizer.Name, "\"", "'") + "\""
+			}
+			b.WriteString(icsFold(line + ":mailto:" + organizer.Address))
+		}
+		if strings.EqualFold(event.Sensitivity, "private") || strings.EqualFold(event.Sensitivity, "confidential") {
+			b.WriteString("CLASS:" + strings.ToUpper(event.Sensitivity) + "\r\n")
+		}
+		b.WriteString("END:VEVENT\r\n")
+	}
+
+	b.WriteString("END:VCALENDAR\r\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,112 @@
+package cal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lcorneliussen/md365/internal/graph"
+)
+
+// recurrenceDays maps two-letter day codes (as in iCalendar RRULE) to Graph day names
+var recurrenceDays = map[string]string{
+	"MO": "monday",
+	"TU": "tuesday",
+	"WE": "wednesday",
+	"TH": "thursday",
+	"FR": "friday",
+	"SA": "saturday",
+	"SU": "sunday",
+}
+
+// parseRecurrence translates a spec like "weekly:MO,WE;count=10" or
+// "daily;until=2026-12-31" into a Graph recurrence starting at start.
+//
+// The frequency is daily, weekly, monthly or yearly. Weekly takes optional
+// days and defaults to the start's weekday; monthly and yearly repeat on the
+// start's day of month. Options are interval=N, count=N and until=YYYY-MM-DD;
+// without count or until the series has no end.
+func parseRecurrence(spec string, start time.Time, timezone string) (*graph.PatternedRecurrence, error) {
+	parts := strings.Split(spec, ";")
+	freq, days, _ := strings.Cut(strings.TrimSpace(parts[0]), ":")
+
+	pattern := graph.RecurrencePattern{Interval: 1}
+	switch strings.ToLower(freq) {
+	case "daily":
+		pattern.Type = "daily"
+	case "weekly":
+		pattern.Type = "weekly"
+		if days == "" {
+			pattern.DaysOfWeek = []string{strings.ToLower(start.Weekday().String())}
+		}
+	case "monthly":
+		pattern.Type = "absoluteMonthly"
+		pattern.DayOfMonth = start.Day()
+	case "yearly":
+		pattern.Type = "absoluteYearly"
+		pattern.DayOfMonth = start.Day()
+		pattern.Month = int(start.Month())
+	default:
+		return nil, fmt.Errorf("invalid recurrence %q: frequency must be daily, weekly, monthly or yearly", spec)
+	}
+
+	if days != "" {
+		if pattern.Type != "weekly" {
+			return nil, fmt.Errorf("invalid recurrence %q: days are only supported for weekly", spec)
+		}
+		for _, day := range strings.Split(days, ",") {
+			name, ok := recurrenceDays[strings.ToUpper(strings.TrimSpace(day))]
+			if !ok {
+				return nil, fmt.Errorf("invalid recurrence %q: unknown day %q (use MO, TU, WE, TH, FR, SA, SU)", spec, day)
+			}
+			pattern.DaysOfWeek = append(pattern.DaysOfWeek, name)
+		}
+	}
+
+	rng := graph.RecurrenceRange{
+		Type:               "noEnd",
+		StartDate:          start.Format("2006-01-02"),
+		RecurrenceTimeZone: timezone,
+	}
+
+	for _, opt := range parts[1:] {
+		key, value, ok := strings.Cut(strings.TrimSpace(opt), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid recurrence %q: expected key=value, got %q", spec, opt)
+		}
+
+		switch strings.ToLower(key) {
+		case "interval":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid recurrence %q: interval must be a positive number", spec)
+			}
+			pattern.Interval = n
+		case "count":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid recurrence %q: count must be a positive number", spec)
+			}
+			rng.Type = "numbered"
+			rng.NumberOfOccurrences = n
+		case "until":
+			if _, err := time.Parse("2006-01-02", value); err != nil {
+				return nil, fmt.Errorf("invalid recurrence %q: until must be a date (YYYY-MM-DD)", spec)
+			}
+			if value < rng.StartDate {
+				return nil, fmt.Errorf("invalid recurrence %q: until is before the start date", spec)
+			}
+			rng.Type = "endDate"
+			rng.EndDate = value
+		default:
+			return nil, fmt.Errorf("invalid recurrence %q: unknown option %q (use interval, count or until)", spec, key)
+		}
+	}
+
+	if rng.NumberOfOccurrences > 0 && rng.EndDate != "" {
+		return nil, fmt.Errorf("invalid recurrence %q: use either count or until", spec)
+	}
+
+	return &graph.PatternedRecurrence{Pattern: pattern, Range: rng}, nil
+}
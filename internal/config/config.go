@@ -1,9 +1,11 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -12,12 +14,39 @@ import (
 // DefaultClientID is the official md365 app registration
 const DefaultClientID = "98a465bc-fdca-4ea6-a3b9-a4b819e50a86"
 
+// DefaultTenant is the multi-tenant authority used when no tenant is configured
+const DefaultTenant = "common"
+
+// Cross-tenant guard modes
+const (
+	CrossTenantStrict = "strict" // Unknown recipient domains are blocked
+	CrossTenantWarn   = "warn"   // Unknown recipient domains only warn (default)
+	CrossTenantOff    = "off"    // No cross-tenant check at all
+)
+
+// Calendar folder layouts
+const (
+	LayoutFlat    = "flat"    // All events in calendar/ (default)
+	LayoutMonthly = "monthly" // Events in calendar/YYYY/MM/
+)
+
 // Config represents the application configuration
 type Config struct {
-	ClientID string              `yaml:"client_id"`
-	DataDir  string              `yaml:"data_dir"`
-	Timezone string              `yaml:"timezone"`
-	Accounts map[string]*Account `yaml:"accounts"`
+	ClientID                string              `yaml:"client_id"`
+	Tenant                  string              `yaml:"tenant,omitempty"`
+	DataDir                 string              `yaml:"data_dir"`
+	Timezone                string              `yaml:"timezone"`
+	Prune                   *bool               `yaml:"prune,omitempty"`
+	DataGitignore           *bool               `yaml:"data_gitignore,omitempty"`
+	RedactPrivate           bool                `yaml:"redact_private,omitempty"`
+	MaxBodyBytes            int                 `yaml:"max_body_bytes,omitempty"`
+	CalendarLayout          string              `yaml:"calendar_layout,omitempty"`
+	CrossTenant             string              `yaml:"cross_tenant,omitempty"`
+	GraphVersion            string              `yaml:"graph_version,omitempty"`
+	DefaultAccount          string              `yaml:"default_account,omitempty"`
+	EventFilenameTemplate   string              `yaml:"event_filename,omitempty"`
+	ContactFilenameTemplate string              `yaml:"contact_filename,omitempty"`
+	Accounts                map[string]*Account `yaml:"accounts"`
 }
 
 // Account represents an account configuration
@@ -27,6 +56,21 @@ type Account struct {
 	Hint     string   `yaml:"hint"`
 	Scope    string   `yaml:"scope"`
 	Domains  []string `yaml:"domains"`
+	Enabled  *bool    `yaml:"enabled,omitempty"`
+
+	// ClientSecretEnv names the environment variable holding the client
+	// secret of a confidential app registration
+	ClientSecretEnv string `yaml:"client_secret_env,omitempty"`
+
+	// CertPath and KeyPath point at the PEM certificate and RSA private key
+	// used to sign client assertions instead of a secret. KeyPath may be
+	// omitted when the key is in the certificate file.
+	CertPath string `yaml:"cert_path,omitempty"`
+	KeyPath  string `yaml:"key_path,omitempty"`
+
+	// UserID is the mailbox (id or userPrincipalName) to use instead of
+	// /me; required for auth_flow clientcredentials
+	UserID string `yaml:"user_id,omitempty"`
 }
 
 // GetClientID returns the account-specific client_id, falling back to global
@@ -37,6 +81,35 @@ func (c *Config) GetClientID(accountName string) string {
 	return c.ClientID
 }
 
+// GetClientSecret returns the client secret of a confidential app from the
+// environment variable named by client_secret_env, or "" for public clients
+func (c *Config) GetClientSecret(accountName string) (string, error) {
+	acc, ok := c.Accounts[accountName]
+	if !ok || acc.ClientSecretEnv == "" {
+		return "", nil
+	}
+	secret := os.Getenv(acc.ClientSecretEnv)
+	if secret == "" {
+		return "", fmt.Errorf("account '%s' needs a client secret, but $%s is not set", accountName, acc.ClientSecretEnv)
+	}
+	return secret, nil
+}
+
+// GetCertPaths returns the certificate and key files for certificate auth,
+// or empty strings if the account uses none
+func (c *Config) GetCertPaths(accountName string) (certPath, keyPath string) {
+	acc, ok := c.Accounts[accountName]
+	if !ok || acc.CertPath == "" {
+		return "", ""
+	}
+	certPath = expandTilde(acc.CertPath)
+	keyPath = certPath
+	if acc.KeyPath != "" {
+		keyPath = expandTilde(acc.KeyPath)
+	}
+	return certPath, keyPath
+}
+
 // GetAuthFlow returns the auth_flow for an account (default: "devicecode")
 func (c *Config) GetAuthFlow(accountName string) string {
 	if acc, ok := c.Accounts[accountName]; ok && acc.AuthFlow != "" {
@@ -45,10 +118,31 @@ func (c *Config) GetAuthFlow(accountName string) string {
 	return "devicecode"
 }
 
+// AccountEnabled reports whether an account takes part in "sync all"
+// (default: true). Unknown accounts are reported as enabled.
+func (c *Config) AccountEnabled(name string) bool {
+	acc, ok := c.Accounts[name]
+	return !ok || acc == nil || acc.Enabled == nil || *acc.Enabled
+}
+
+// PruneEnabled reports whether sync may delete local files (default: true)
+func (c *Config) PruneEnabled() bool {
+	return c.Prune == nil || *c.Prune
+}
+
+// GitignoreEnabled reports whether sync writes a .gitignore into a newly
+// created data directory (default: true)
+func (c *Config) GitignoreEnabled() bool {
+	return c.DataGitignore == nil || *c.DataGitignore
+}
+
 var (
-	configDir  string
-	configFile string
-	dataDir    string
+	baseConfigDir string
+	baseDataDir   string
+	profile       string
+	configDir     string
+	configFile    string
+	dataDir       string
 )
 
 func init() {
@@ -57,18 +151,58 @@ func init() {
 	if xdgConfig == "" {
 		xdgConfig = filepath.Join(os.Getenv("HOME"), ".config")
 	}
-	configDir = filepath.Join(xdgConfig, "md365")
-	configFile = filepath.Join(configDir, "config.yaml")
+	baseConfigDir = filepath.Join(xdgConfig, "md365")
 
 	// Set up data directory
 	xdgData := os.Getenv("XDG_DATA_HOME")
 	if xdgData == "" {
 		xdgData = filepath.Join(os.Getenv("HOME"), ".local", "share")
 	}
-	dataDir = filepath.Join(xdgData, "md365")
+	baseDataDir = filepath.Join(xdgData, "md365")
+
+	setPaths()
+}
+
+// setPaths derives the config and data paths from the base directories and
+// the active profile
+func setPaths() {
+	configDir = baseConfigDir
+	dataDir = baseDataDir
+	if profile != "" {
+		configDir = filepath.Join(baseConfigDir, "profiles", profile)
+		dataDir = filepath.Join(baseDataDir, "profiles", profile)
+	}
+	configFile = filepath.Join(configDir, "config.yaml")
+}
+
+// SetProfile namespaces the config file, data directory and tokens under
+// profiles/<name>, so profiles never share state. Call it before
+// SetConfigPath, which still takes precedence for the config file.
+func SetProfile(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid profile name %q", name)
+	}
+	profile = name
+	setPaths()
+	return nil
+}
+
+// GetProfile returns the active profile, or "" for the default one
+func GetProfile() string {
+	return profile
+}
+
+// GetBaseConfigDir returns the configuration directory shared by all
+// profiles, before profile namespacing
+func GetBaseConfigDir() string {
+	return baseConfigDir
 }
 
-// Load reads and parses the configuration file
+// Load reads and parses the configuration file.
+//
+// Values are resolved with the precedence: environment > config file > defaults.
+// Supported environment variables are MD365_CLIENT_ID, MD365_TENANT,
+// MD365_DATA_DIR and MD365_TIMEZONE.
 func Load() (*Config, error) {
 	data, err := os.ReadFile(configFile)
 	if err != nil {
@@ -90,11 +224,54 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
+	// Environment overrides take precedence over the config file
+	applyEnvOverrides(&cfg)
+
 	// Default to official md365 app registration if no client_id configured
 	if cfg.ClientID == "" {
 		cfg.ClientID = DefaultClientID
 	}
 
+	// Default to the multi-tenant authority
+	if cfg.Tenant == "" {
+		cfg.Tenant = DefaultTenant
+	}
+
+	// Default to warning on unknown recipient domains
+	switch cfg.CrossTenant {
+	case "":
+		cfg.CrossTenant = CrossTenantWarn
+	case CrossTenantStrict, CrossTenantWarn, CrossTenantOff:
+	default:
+		return nil, fmt.Errorf("invalid cross_tenant %q in config (use strict, warn or off)", cfg.CrossTenant)
+	}
+
+	// Default to the stable Graph API
+	switch cfg.GraphVersion {
+	case "":
+		cfg.GraphVersion = "v1.0"
+	case "v1.0", "beta":
+	default:
+		return nil, fmt.Errorf("invalid graph_version %q in config (use v1.0 or beta)", cfg.GraphVersion)
+	}
+
+	if err := cfg.validateFilenameTemplates(); err != nil {
+		return nil, err
+	}
+
+	// Default to all events in one folder
+	switch cfg.CalendarLayout {
+	case "":
+		cfg.CalendarLayout = LayoutFlat
+	case LayoutFlat, LayoutMonthly:
+	default:
+		return nil, fmt.Errorf("invalid calendar_layout %q in config (use flat or monthly)", cfg.CalendarLayout)
+	}
+
+	if cfg.MaxBodyBytes < 0 {
+		return nil, fmt.Errorf("invalid max_body_bytes %d in config (use 0 for unlimited)", cfg.MaxBodyBytes)
+	}
+
 	// Set default timezone
 	if cfg.Timezone == "" {
 		cfg.Timezone = "UTC"
@@ -110,6 +287,22 @@ func Load() (*Config, error) {
 	return &cfg, nil
 }
 
+// applyEnvOverrides overrides config values with MD365_* environment variables
+func applyEnvOverrides(cfg *Config) {
+	if v := os.Getenv("MD365_CLIENT_ID"); v != "" {
+		cfg.ClientID = v
+	}
+	if v := os.Getenv("MD365_TENANT"); v != "" {
+		cfg.Tenant = v
+	}
+	if v := os.Getenv("MD365_DATA_DIR"); v != "" {
+		cfg.DataDir = v
+	}
+	if v := os.Getenv("MD365_TIMEZONE"); v != "" {
+		cfg.Timezone = v
+	}
+}
+
 // expandTilde expands ~ to home directory
 func expandTilde(path string) string {
 	if strings.HasPrefix(path, "~") {
@@ -118,11 +311,171 @@ func expandTilde(path string) string {
 	return path
 }
 
+// ResolvedConfig is the effective configuration after defaults and overrides
+type ResolvedConfig struct {
+	ConfigFile      string            `json:"config_file"`
+	ClientID        string            `json:"client_id"`
+	Tenant          string            `json:"tenant"`
+	DataDir         string            `json:"data_dir"`
+	Timezone        string            `json:"timezone"`
+	CrossTenant     string            `json:"cross_tenant"`
+	GraphVersion    string            `json:"graph_version"`
+	DefaultAccount  string            `json:"default_account,omitempty"`
+	EventFilename   string            `json:"event_filename"`
+	ContactFilename string            `json:"contact_filename"`
+	RedactPrivate   bool              `json:"redact_private"`
+	MaxBodyBytes    int               `json:"max_body_bytes,omitempty"`
+	CalendarLayout  string            `json:"calendar_layout"`
+	Accounts        []ResolvedAccount `json:"accounts"`
+}
+
+// ResolvedAccount is the effective configuration of a single account
+type ResolvedAccount struct {
+	Name     string   `json:"name"`
+	ClientID string   `json:"client_id"`
+	AuthFlow string   `json:"auth_flow"`
+	Hint     string   `json:"hint,omitempty"`
+	Scope    string   `json:"scope,omitempty"`
+	Domains  []string `json:"domains,omitempty"`
+	Enabled  bool     `json:"enabled"`
+
+	ClientSecretEnv string `json:"client_secret_env,omitempty"`
+	CertPath        string `json:"cert_path,omitempty"`
+	KeyPath         string `json:"key_path,omitempty"`
+	UserID          string `json:"user_id,omitempty"`
+}
+
+// Resolve returns the effective configuration using the account getters
+func (c *Config) Resolve() *ResolvedConfig {
+	resolved := &ResolvedConfig{
+		ConfigFile:      configFile,
+		ClientID:        c.ClientID,
+		Tenant:          c.Tenant,
+		DataDir:         c.DataDir,
+		Timezone:        c.Timezone,
+		CrossTenant:     c.CrossTenant,
+		GraphVersion:    c.GraphVersion,
+		DefaultAccount:  c.DefaultAccount,
+		EventFilename:   c.eventFilenameTemplate(),
+		ContactFilename: c.contactFilenameTemplate(),
+		RedactPrivate:   c.RedactPrivate,
+		MaxBodyBytes:    c.MaxBodyBytes,
+		CalendarLayout:  c.CalendarLayout,
+		Accounts:        []ResolvedAccount{},
+	}
+
+	names := c.ListAccounts()
+	sort.Strings(names)
+	for _, name := range names {
+		acc := c.Accounts[name]
+		resolved.Accounts = append(resolved.Accounts, ResolvedAccount{
+			Name:     name,
+			ClientID: c.GetClientID(name),
+			AuthFlow: c.GetAuthFlow(name),
+			Hint:     acc.Hint,
+			Scope:    acc.Scope,
+			Domains:  acc.Domains,
+			Enabled:  c.AccountEnabled(name),
+
+			ClientSecretEnv: acc.ClientSecretEnv,
+			CertPath:        acc.CertPath,
+			KeyPath:         acc.KeyPath,
+			UserID:          acc.UserID,
+		})
+	}
+
+	return resolved
+}
+
+// Show prints the effective configuration as text or JSON
+func Show(cfg *Config, asJSON bool) error {
+	resolved := cfg.Resolve()
+
+	if asJSON {
+		data, err := json.MarshalIndent(resolved, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal config: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("Config file: %s\n", resolved.ConfigFile)
+	fmt.Printf("Client ID:   %s\n", resolved.ClientID)
+	fmt.Printf("Tenant:      %s\n", resolved.Tenant)
+	fmt.Printf("Data dir:    %s\n", resolved.DataDir)
+	fmt.Printf("Timezone:    %s\n", resolved.Timezone)
+	fmt.Printf("Cross-tenant: %s\n", resolved.CrossTenant)
+	fmt.Printf("Graph API:   %s\n", resolved.GraphVersion)
+	if resolved.DefaultAccount != "" {
+		fmt.Printf("Default account: %s\n", resolved.DefaultAccount)
+	}
+	fmt.Printf("Calendar layout: %s\n", resolved.CalendarLayout)
+	fmt.Printf("Event files:   %s.md\n", resolved.EventFilename)
+	fmt.Printf("Contact files: %s.md\n", resolved.ContactFilename)
+	if resolved.RedactPrivate {
+		fmt.Println("Redact private events: yes")
+	}
+	if resolved.MaxBodyBytes > 0 {
+		fmt.Printf("Max body size: %d bytes\n", resolved.MaxBodyBytes)
+	}
+	fmt.Println()
+	fmt.Println("Accounts:")
+
+	for _, acc := range resolved.Accounts {
+		fmt.Printf("  %s:\n", acc.Name)
+		fmt.Printf("    Client ID: %s\n", acc.ClientID)
+		fmt.Printf("    Auth flow: %s\n", acc.AuthFlow)
+		if acc.Hint != "" {
+			fmt.Printf("    Hint:      %s\n", acc.Hint)
+		}
+		if acc.UserID != "" {
+			fmt.Printf("    User:      %s\n", acc.UserID)
+		}
+		if acc.ClientSecretEnv != "" {
+			fmt.Printf("    Secret:    $%s\n", acc.ClientSecretEnv)
+		}
+		if acc.CertPath != "" {
+			fmt.Printf("    Cert:      %s\n", acc.CertPath)
+		}
+		if acc.KeyPath != "" {
+			fmt.Printf("    Key:       %s\n", acc.KeyPath)
+		}
+		if acc.Scope != "" {
+			fmt.Printf("    Scope:     %s\n", acc.Scope)
+		}
+		if len(acc.Domains) > 0 {
+			fmt.Printf("    Domains:   %s\n", strings.Join(acc.Domains, ", "))
+		}
+		if !acc.Enabled {
+			fmt.Printf("    Enabled:   false (skipped by sync all)\n")
+		}
+	}
+
+	return nil
+}
+
+// SetConfigPath overrides the configuration file used by Load and SaveAccount
+func SetConfigPath(path string) {
+	configFile = expandTilde(path)
+	configDir = filepath.Dir(configFile)
+}
+
+// GetConfigPath returns the configuration file path
+func GetConfigPath() string {
+	return configFile
+}
+
 // GetConfigDir returns the configuration directory path
 func GetConfigDir() string {
 	return configDir
 }
 
+// SetDataDir overrides the data directory of a loaded configuration
+func (c *Config) SetDataDir(path string) {
+	c.DataDir = expandTilde(path)
+}
+
 // GetDataDir returns the default data directory path
 func GetDataDir() string {
 	return dataDir
@@ -148,9 +501,10 @@ func (c *Config) GetAccount(name string) (*Account, error) {
 
 // CheckCrossTenant validates recipient emails against account domains
 // Returns error if recipient belongs to another account's domain
-// Returns warning (but allows) if domain is unknown
+// Unknown domains warn (warn mode) or return an error (strict mode);
+// in off mode no check is done
 func (c *Config) CheckCrossTenant(account string, recipientEmails []string) error {
-	if len(recipientEmails) == 0 {
+	if len(recipientEmails) == 0 || c.CrossTenant == CrossTenantOff {
 		return nil
 	}
 
@@ -182,6 +536,11 @@ func (c *Config) CheckCrossTenant(account string, recipientEmails []string) erro
 			}
 		}
 
+		if c.CrossTenant == CrossTenantStrict {
+			return fmt.Errorf("recipient %s has unknown domain %s (cross_tenant: strict). Add it to the domains of an account or override with --force",
+				email, domain)
+		}
+
 		// Unknown domain - warn but proceed
 		fmt.Fprintf(os.Stderr, "Warning: Unknown domain %s for %s. Proceeding with account '%s'.\n",
 			domain, email, account)
@@ -196,13 +555,22 @@ func extractDomain(email string) string {
 	if len(parts) != 2 {
 		return ""
 	}
-	return strings.ToLower(strings.TrimSpace(parts[1]))
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(parts[1])), ".")
 }
 
-// containsDomain checks if domain is in the list (case-insensitive)
+// containsDomain checks if domain is in the list (case-insensitive).
+// Entries starting with "*." match any subdomain, e.g. "*.company.com"
+// matches "eu.company.com" but not "company.com" itself.
 func containsDomain(domains []string, domain string) bool {
 	for _, d := range domains {
-		if strings.EqualFold(d, domain) {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if suffix, ok := strings.CutPrefix(d, "*."); ok {
+			if strings.HasSuffix(domain, "."+suffix) {
+				return true
+			}
+			continue
+		}
+		if d == domain {
 			return true
 		}
 	}
@@ -211,15 +579,22 @@ func containsDomain(domains []string, domain string) bool {
 
 // SaveAccount adds or updates an account in the configuration file
 func SaveAccount(name string, account *Account) error {
-	// Load existing config or create minimal one
-	cfg, err := Load()
-	if err != nil {
-		// Create a new config if file doesn't exist
-		cfg = &Config{
+	// Patch the file as-is, without applying defaults or environment
+	// overrides; only a missing file starts a fresh config
+	var cfg Config
+	data, err := os.ReadFile(configFile)
+	switch {
+	case err == nil:
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return fmt.Errorf("failed to parse config file: %w", err)
+		}
+	case os.IsNotExist(err):
+		cfg = Config{
 			ClientID: DefaultClientID,
 			Timezone: "Europe/Berlin",
-			Accounts: make(map[string]*Account),
 		}
+	default:
+		return fmt.Errorf("failed to read config file: %w", err)
 	}
 
 	// Initialize accounts map if needed
@@ -235,6 +610,32 @@ func SaveAccount(name string, account *Account) error {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
+	return writeConfigFile(&cfg)
+}
+
+// SetDefaultAccount sets default_account in the configuration file. The
+// file is updated as-is, without applying defaults or environment overrides.
+func SetDefaultAccount(name string) error {
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	if _, ok := cfg.Accounts[name]; !ok {
+		return fmt.Errorf("account '%s' not found in config", name)
+	}
+	cfg.DefaultAccount = name
+
+	return writeConfigFile(&cfg)
+}
+
+// writeConfigFile writes the configuration file
+func writeConfigFile(cfg *Config) error {
 	// Marshal to YAML
 	data, err := yaml.Marshal(cfg)
 	if err != nil {
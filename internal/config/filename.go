@@ -0,0 +1,85 @@
+package config
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Default filename templates, without the .md extension
+const (
+	DefaultEventFilename   = "{{.Date}}-{{.Slug}}"
+	DefaultContactFilename = "{{.Slug}}"
+)
+
+// FilenameData is what event_filename and contact_filename templates are
+// evaluated against
+type FilenameData struct {
+	ID      string // Graph item ID
+	ShortID string // 8 hex digits derived from the ID, stable when the subject changes
+	Slug    string // Slugified subject or display name
+	Name    string // Subject or display name as is
+	Date    string // Event start date (YYYY-MM-DD); empty for contacts
+	Account string
+}
+
+// EventFilename renders the event_filename template for an event
+func (c *Config) EventFilename(data FilenameData) (string, error) {
+	return renderFilename("event_filename", c.eventFilenameTemplate(), data)
+}
+
+// ContactFilename renders the contact_filename template for a contact
+func (c *Config) ContactFilename(data FilenameData) (string, error) {
+	return renderFilename("contact_filename", c.contactFilenameTemplate(), data)
+}
+
+func (c *Config) eventFilenameTemplate() string {
+	if c.EventFilenameTemplate == "" {
+		return DefaultEventFilename
+	}
+	return c.EventFilenameTemplate
+}
+
+func (c *Config) contactFilenameTemplate() string {
+	if c.ContactFilenameTemplate == "" {
+		return DefaultContactFilename
+	}
+	return c.ContactFilenameTemplate
+}
+
+// validateFilenameTemplates checks that both templates parse and only use
+// known fields
+func (c *Config) validateFilenameTemplates() error {
+	sample := FilenameData{ID: "id", Slug: "slug", Name: "Name", Date: "2006-01-02", Account: "account"}
+	if _, err := renderFilename("event_filename", c.eventFilenameTemplate(), sample); err != nil {
+		return err
+	}
+	if _, err := renderFilename("contact_filename", c.contactFilenameTemplate(), sample); err != nil {
+		return err
+	}
+	return nil
+}
+
+// renderFilename executes a filename template. Path separators in the result
+// are replaced so files always stay in their directory.
+func renderFilename(name, text string, data FilenameData) (string, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s template: %w", name, err)
+	}
+
+	if data.ShortID == "" && data.ID != "" {
+		sum := sha1.Sum([]byte(data.ID))
+		data.ShortID = hex.EncodeToString(sum[:])[:8]
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("invalid %s template: %w", name, err)
+	}
+
+	filename := strings.NewReplacer("/", "-", `\`, "-").Replace(b.String())
+	return strings.Trim(strings.TrimSpace(filename), "."), nil
+}
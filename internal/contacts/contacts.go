@@ -1,27 +1,364 @@
 package contacts
 
 import (
+	"encoding/csv"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"text/template"
+	"unicode"
 
 	"github.com/lcorneliussen/md365/internal/config"
+	"github.com/lcorneliussen/md365/internal/output"
 	"gopkg.in/yaml.v3"
 )
 
+// ContactInfo represents parsed contact information for search results
+type ContactInfo struct {
+	ID          string   `json:"id"`
+	DisplayName string   `json:"display_name"`
+	Emails      []string `json:"emails,omitempty"`
+	Account     string   `json:"account"`
+	FilePath    string   `json:"file"`
+}
+
+// SearchOptions controls which contacts Search returns
+type SearchOptions struct {
+	Query   string
+	Regex   bool   // Treat Query as a regular expression
+	Account string // Empty for all accounts
+	Format  string // Go template per contact over ContactDetails instead of the default line
+	Limit   int    // If > 0, print at most this many contacts
+}
+
 // Search searches for contacts matching a query
-func Search(cfg *config.Config, query, account string) error {
-	// Determine which accounts to search
-	var accounts []string
-	if account != "" {
-		accounts = []string{account}
-	} else {
-		accounts = cfg.ListAccounts()
+func Search(cfg *config.Config, opts SearchOptions) error {
+	accounts := selectAccounts(cfg, opts.Account)
+
+	// Case-insensitive substring match by default, shared with cal list
+	matches, err := output.Matcher(opts.Query, opts.Regex)
+	if err != nil {
+		return err
+	}
+
+	var format *template.Template
+	if opts.Format != "" {
+		// One email and phone, so that {{index .Emails 0}} validates
+		sample := ContactDetails{Emails: []string{""}, Phones: []string{""}}
+		if format, err = output.Template(opts.Format, sample); err != nil {
+			return err
+		}
+	}
+
+	results := []ContactInfo{}
+
+	err = walkContacts(cfg, accounts, func(contact ContactInfo, content string) {
+		if matches(content) {
+			results = append(results, contact)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	results, limitNote := output.Limit(results, opts.Limit)
+	defer limitNote()
+
+	if output.JSON() {
+		return output.PrintJSON(results)
+	}
+
+	if format != nil {
+		for _, contact := range results {
+			details, err := readContactFile(contact.FilePath)
+			if err != nil {
+				return err
+			}
+
+			// Render first so a contact the template can't handle (e.g. no
+			// email for {{index .Emails 0}}) doesn't leave a partial line
+			var line strings.Builder
+			if err := format.Execute(&line, details); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", contact.FilePath, err)
+				continue
+			}
+			fmt.Println(line.String())
+		}
+		return nil
+	}
+
+	// Display contacts with their first email if available
+	for _, contact := range results {
+		line := fmt.Sprintf("[%s] %s", contact.Account, contact.DisplayName)
+		if len(contact.Emails) > 0 {
+			line += fmt.Sprintf(" <%s>", contact.Emails[0])
+		}
+
+		fmt.Println(line)
+	}
+
+	return nil
+}
+
+// ContactDetails is the full content of a contact file
+type ContactDetails struct {
+	ID           string   `json:"id" yaml:"id"`
+	Account      string   `json:"account" yaml:"account"`
+	DisplayName  string   `json:"display_name" yaml:"display_name"`
+	GivenName    string   `json:"given_name,omitempty" yaml:"given_name"`
+	Surname      string   `json:"surname,omitempty" yaml:"surname"`
+	Emails       []string `json:"emails,omitempty" yaml:"emails"`
+	Phones       []string `json:"phones,omitempty" yaml:"phones"`
+	Company      string   `json:"company,omitempty" yaml:"company"`
+	JobTitle     string   `json:"job_title,omitempty" yaml:"job_title"`
+	Birthday     string   `json:"birthday,omitempty" yaml:"birthday"`
+	LastModified string   `json:"last_modified,omitempty" yaml:"last_modified"`
+	Body         string   `json:"body,omitempty" yaml:"-"`
+	FilePath     string   `json:"file" yaml:"-"`
+}
+
+// Show prints all details of one contact, given by file or by ID
+func Show(cfg *config.Config, account, id, filePath string) error {
+	if filePath == "" {
+		if id == "" {
+			return fmt.Errorf("either a file or --id is required")
+		}
+
+		err := walkContacts(cfg, selectAccounts(cfg, account), func(contact ContactInfo, _ string) {
+			if filePath == "" && contact.ID == id {
+				filePath = contact.FilePath
+			}
+		})
+		if err != nil {
+			return err
+		}
+		if filePath == "" {
+			return fmt.Errorf("contact %s not found", id)
+		}
+	}
+
+	contact, err := readContactFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	if output.JSON() {
+		return output.PrintJSON(contact)
+	}
+
+	fmt.Println(contact.DisplayName)
+	field := func(label, value string) {
+		if value != "" {
+			fmt.Printf("  %-10s %s\n", label+":", value)
+		}
+	}
+	for _, email := range contact.Emails {
+		field("Email", email)
+	}
+	for _, phone := range contact.Phones {
+		field("Phone", phone)
+	}
+	field("Company", contact.Company)
+	field("Title", contact.JobTitle)
+	birthday, _, _ := strings.Cut(contact.Birthday, "T")
+	field("Birthday", birthday)
+	field("Account", contact.Account)
+	field("ID", contact.ID)
+	field("File", contact.FilePath)
+
+	if contact.Body != "" {
+		fmt.Printf("\n%s\n", contact.Body)
+	}
+
+	return nil
+}
+
+// readContactFile parses the frontmatter and body of a contact file
+func readContactFile(path string) (*ContactDetails, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	parts := strings.SplitN(string(data), "---", 3)
+	if len(parts) < 3 {
+		return nil, fmt.Errorf("invalid contact file format: %s", path)
+	}
+
+	var contact ContactDetails
+	if err := yaml.Unmarshal([]byte(parts[1]), &contact); err != nil {
+		return nil, fmt.Errorf("failed to parse frontmatter: %w", err)
+	}
+	contact.Body = strings.TrimSpace(parts[2])
+	contact.FilePath = path
+
+	return &contact, nil
+}
+
+// Export writes all contacts of an account (or all accounts) as CSV to out,
+// or to stdout if out is empty or "-"
+func Export(cfg *config.Config, account, format, out string) error {
+	if format != "csv" {
+		return fmt.Errorf("invalid export format %q (only csv is supported)", format)
+	}
+
+	var all []*ContactDetails
+	err := walkContacts(cfg, selectAccounts(cfg, account), func(contact ContactInfo, _ string) {
+		details, err := readContactFile(contact.FilePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", contact.FilePath, err)
+			return
+		}
+		all = append(all, details)
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		return strings.ToLower(all[i].DisplayName) < strings.ToLower(all[j].DisplayName)
+	})
+
+	w := os.Stdout
+	if out != "" && out != "-" {
+		f, err := os.Create(out)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", out, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"name", "given_name", "surname", "emails", "phones", "company", "job_title", "birthday", "account"})
+	for _, c := range all {
+		birthday, _, _ := strings.Cut(c.Birthday, "T")
+		cw.Write([]string{
+			c.DisplayName,
+			c.GivenName,
+			c.Surname,
+			strings.Join(c.Emails, "; "),
+			strings.Join(c.Phones, "; "),
+			c.Company,
+			c.JobTitle,
+			birthday,
+			c.Account,
+		})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+
+	if w != os.Stdout {
+		output.Infof("Exported %d contacts to %s\n", len(all), out)
+	}
+	return nil
+}
+
+// ExportDir writes one contacts-<account>.<format> file per account into
+// dir, creating it if needed. A non-empty account limits it to that account.
+func ExportDir(cfg *config.Config, account, format, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	for _, acc := range selectAccounts(cfg, account) {
+		out := filepath.Join(dir, fmt.Sprintf("contacts-%s.%s", acc, format))
+		if err := Export(cfg, acc, format, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Resolve turns a "@Name" recipient into the primary email of the synced
+// contact it names; anything else is returned unchanged. A contact whose
+// display name or an email equals the name (case-insensitive) wins over
+// partial matches. Several different people matching is an error listing
+// them.
+func Resolve(cfg *config.Config, recipient string) (string, error) {
+	name, ok := strings.CutPrefix(strings.TrimSpace(recipient), "@")
+	if !ok {
+		return recipient, nil
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("empty contact name in %q", recipient)
+	}
+	query := strings.ToLower(name)
+
+	var exact, partial []ContactInfo
+	err := walkContacts(cfg, cfg.ListAccounts(), func(contact ContactInfo, _ string) {
+		if len(contact.Emails) == 0 {
+			return
+		}
+		candidates := append([]string{contact.DisplayName}, contact.Emails...)
+		for _, c := range candidates {
+			if strings.EqualFold(c, name) {
+				exact = append(exact, contact)
+				return
+			}
+		}
+		for _, c := range candidates {
+			if strings.Contains(strings.ToLower(c), query) {
+				partial = append(partial, contact)
+				return
+			}
+		}
+	})
+	if err != nil {
+		return "", err
 	}
 
-	queryLower := strings.ToLower(query)
+	matches := exact
+	if len(matches) == 0 {
+		matches = partial
+	}
 
+	// The same person synced from several accounts is one match
+	var unique []ContactInfo
+	seen := make(map[string]bool)
+	for _, contact := range matches {
+		email := strings.ToLower(contact.Emails[0])
+		if !seen[email] {
+			seen[email] = true
+			unique = append(unique, contact)
+		}
+	}
+
+	switch len(unique) {
+	case 0:
+		return "", fmt.Errorf("no synced contact with an email matches %q", recipient)
+	case 1:
+		email := unique[0].Emails[0]
+		output.Progressf("Resolved %s to %s\n", recipient, email)
+		return email, nil
+	}
+
+	sort.Slice(unique, func(i, j int) bool {
+		return strings.ToLower(unique[i].DisplayName) < strings.ToLower(unique[j].DisplayName)
+	})
+	candidates := make([]string, len(unique))
+	for i, contact := range unique {
+		candidates[i] = fmt.Sprintf("  %s <%s>", contact.DisplayName, contact.Emails[0])
+	}
+	return "", fmt.Errorf("%q matches %d contacts; use a fuller name or the email:\n%s", recipient, len(unique), strings.Join(candidates, "\n"))
+}
+
+// selectAccounts returns the given account, or all accounts if empty
+func selectAccounts(cfg *config.Config, account string) []string {
+	if account != "" {
+		return []string{account}
+	}
+	return cfg.ListAccounts()
+}
+
+// walkContacts parses every contact file of the given accounts and calls fn
+// with the parsed contact and the raw file content
+func walkContacts(cfg *config.Config, accounts []string, fn func(contact ContactInfo, content string)) error {
 	for _, acc := range accounts {
 		contactDir := filepath.Join(cfg.DataDir, acc, "contacts")
 		if _, err := os.Stat(contactDir); os.IsNotExist(err) {
@@ -39,11 +376,6 @@ func Search(cfg *config.Config, query, account string) error {
 				return nil
 			}
 
-			// Search in file content
-			if !strings.Contains(strings.ToLower(string(data)), queryLower) {
-				return nil
-			}
-
 			// Parse frontmatter
 			content := string(data)
 			parts := strings.SplitN(content, "---", 3)
@@ -57,23 +389,25 @@ func Search(cfg *config.Config, query, account string) error {
 			}
 
 			// Extract fields
+			id, _ := fm["id"].(string)
 			displayName, _ := fm["display_name"].(string)
 
-			// Get first email if available
-			email := ""
-			if emails, ok := fm["emails"].([]interface{}); ok && len(emails) > 0 {
-				if e, ok := emails[0].(string); ok {
-					email = e
+			var emails []string
+			if list, ok := fm["emails"].([]interface{}); ok {
+				for _, e := range list {
+					if e, ok := e.(string); ok {
+						emails = append(emails, e)
+					}
 				}
 			}
 
-			// Display contact
-			line := fmt.Sprintf("[%s] %s", acc, displayName)
-			if email != "" {
-				line += fmt.Sprintf(" <%s>", email)
-			}
-
-			fmt.Println(line)
+			fn(ContactInfo{
+				ID:          id,
+				DisplayName: displayName,
+				Emails:      emails,
+				Account:     acc,
+				FilePath:    path,
+			}, content)
 
 			return nil
 		})
@@ -85,3 +419,153 @@ func Search(cfg *config.Config, query, account string) error {
 
 	return nil
 }
+
+// Dedupe match strategies
+const (
+	MatchEmail = "email"
+	MatchName  = "name"
+	MatchBoth  = "both"
+)
+
+// DuplicateCluster is a group of contacts that look like the same person
+type DuplicateCluster struct {
+	Key      string        `json:"key"`
+	Contacts []ContactInfo `json:"contacts"`
+}
+
+// Dedupe reports clusters of contacts sharing an email address or a
+// normalized name. It never modifies any files.
+func Dedupe(cfg *config.Config, account string, by string) error {
+	if by != MatchEmail && by != MatchName && by != MatchBoth {
+		return fmt.Errorf("invalid match strategy %q (use email, name or both)", by)
+	}
+
+	var all []ContactInfo
+	err := walkContacts(cfg, selectAccounts(cfg, account), func(contact ContactInfo, _ string) {
+		all = append(all, contact)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Union-find over contact indexes, joined by shared keys
+	parent := make([]int, len(all))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	firstByKey := map[string]int{}
+	link := func(key string, i int) {
+		if key == "" {
+			return
+		}
+		if j, ok := firstByKey[key]; ok {
+			parent[find(i)] = find(j)
+			return
+		}
+		firstByKey[key] = i
+	}
+
+	for i, contact := range all {
+		if by == MatchEmail || by == MatchBoth {
+			for _, email := range contact.Emails {
+				link("email:"+strings.ToLower(strings.TrimSpace(email)), i)
+			}
+		}
+		if by == MatchName || by == MatchBoth {
+			if name := normalizeName(contact.DisplayName); name != "" {
+				link("name:"+name, i)
+			}
+		}
+	}
+
+	// Collect clusters in walk order
+	members := map[int][]int{}
+	var roots []int
+	for i := range all {
+		root := find(i)
+		if _, ok := members[root]; !ok {
+			roots = append(roots, root)
+		}
+		members[root] = append(members[root], i)
+	}
+
+	clusters := []DuplicateCluster{}
+	for _, root := range roots {
+		if len(members[root]) < 2 {
+			continue
+		}
+		cluster := DuplicateCluster{Key: clusterKey(all, members[root], by)}
+		for _, i := range members[root] {
+			cluster.Contacts = append(cluster.Contacts, all[i])
+		}
+		clusters = append(clusters, cluster)
+	}
+
+	if output.JSON() {
+		return output.PrintJSON(clusters)
+	}
+
+	if len(clusters) == 0 {
+		fmt.Println("No duplicates found")
+		return nil
+	}
+
+	for _, cluster := range clusters {
+		fmt.Printf("%s (%d contacts)\n", cluster.Key, len(cluster.Contacts))
+		for _, contact := range cluster.Contacts {
+			fmt.Printf("  [%s] %s  %s  %s\n", contact.Account, contact.DisplayName, contact.ID, contact.FilePath)
+		}
+	}
+	fmt.Printf("\n%d suspected duplicate cluster(s)\n", len(clusters))
+
+	return nil
+}
+
+// normalizeName lowercases a name and collapses whitespace and punctuation,
+// so "Doe, Jane" and "jane doe" compare equal
+func normalizeName(name string) string {
+	fields := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	sort.Strings(fields)
+	return strings.Join(fields, " ")
+}
+
+// clusterKey describes what a cluster's members have in common
+func clusterKey(all []ContactInfo, members []int, by string) string {
+	counts := map[string]int{}
+	for _, i := range members {
+		if by == MatchEmail || by == MatchBoth {
+			seen := map[string]bool{}
+			for _, email := range all[i].Emails {
+				email = strings.ToLower(strings.TrimSpace(email))
+				if !seen[email] {
+					seen[email] = true
+					counts[email]++
+				}
+			}
+		}
+		if by == MatchName || by == MatchBoth {
+			if name := normalizeName(all[i].DisplayName); name != "" {
+				counts[name]++
+			}
+		}
+	}
+
+	var shared []string
+	for key, n := range counts {
+		if n > 1 {
+			shared = append(shared, key)
+		}
+	}
+	sort.Strings(shared)
+	return strings.Join(shared, ", ")
+}
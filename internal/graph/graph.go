@@ -2,46 +2,257 @@ package graph
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/mail"
+	neturl "net/url"
+	"os"
+	"path"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
 const (
-	baseURL = "https://graph.microsoft.com/v1.0"
+	// graphRoot is the Graph endpoint; the API version is appended to it
+	graphRoot = "https://graph.microsoft.com"
+
+	// DefaultVersion is the API version used unless beta is requested
+	DefaultVersion = "v1.0"
+
+	// calendarPageSize is the $top page size requested for calendar views
+	calendarPageSize = 100
+
+	// maxBatchSize is the maximum number of requests in one $batch call
+	maxBatchSize = 20
+
+	// deltaMaxAttempts bounds the attempts per delta page on transient errors
+	deltaMaxAttempts = 3
+
+	// eventSelectFields are the event properties consumed by sync.WriteEventFile.
+	// Keep in sync with the Event struct.
+	eventSelectFields = "id,subject,start,end,isAllDay,location,organizer,attendees,responseStatus," +
+		"isOnlineMeeting,onlineMeeting,categories,sensitivity,lastModifiedDateTime,body,webLink,isOrganizer"
+
+	// contactSelectFields are the contact properties consumed by sync.WriteContactFile.
+	// Keep in sync with the Contact struct.
+	contactSelectFields = "id,displayName,givenName,surname,emailAddresses,businessPhones,homePhones," +
+		"mobilePhone,companyName,jobTitle,birthday,lastModifiedDateTime"
+)
+
+// Log levels for HTTP request logging
+const (
+	logQuiet = iota
+	logVerbose
+	logDebug
+)
+
+// logLevel controls HTTP request logging to stderr
+var logLevel = logQuiet
+
+// SetVerbose enables request logging. Verbose logs method, URL and status;
+// debug additionally logs headers (Authorization redacted) and response bodies.
+func SetVerbose(verbose, debug bool) {
+	switch {
+	case debug:
+		logLevel = logDebug
+	case verbose:
+		logLevel = logVerbose
+	default:
+		logLevel = logQuiet
+	}
+}
+
+// SetInsecureSkipVerify disables TLS certificate verification for all HTTP
+// requests, including sign-in. Only for testing against local mock servers.
+func SetInsecureSkipVerify() {
+	fmt.Fprintln(os.Stderr, "WARNING: TLS certificate verification is disabled (--insecure-skip-verify). Never use this against real servers.")
+
+	transport := http.DefaultTransport.(*http.Transport)
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	transport.TLSClientConfig.InsecureSkipVerify = true
+}
+
+// dumpDir receives a copy of every raw response body if set (--dump)
+var (
+	dumpDir string
+	dumpSeq atomic.Int64
 )
 
+// SetDumpDir makes every Graph response body get written to a timestamped
+// file in dir, for attaching raw payloads to bug reports
+func SetDumpDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create dump directory: %w", err)
+	}
+	dumpDir = dir
+	return nil
+}
+
+// dumpResponse writes a raw response body to dumpDir; failures only warn
+func dumpResponse(req *http.Request, resp *http.Response, body []byte) {
+	if dumpDir == "" {
+		return
+	}
+
+	name := path.Base(req.URL.Path)
+	if name == "/" || name == "." {
+		name = "response"
+	}
+	file := fmt.Sprintf("%s-%04d-%s-%d-%s.json",
+		time.Now().Format("20060102-150405.000"), dumpSeq.Add(1), req.Method, resp.StatusCode, name)
+
+	if err := os.WriteFile(filepath.Join(dumpDir, file), body, 0600); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to dump response: %v\n", err)
+		return
+	}
+	logf(logVerbose, "    dumped to %s", filepath.Join(dumpDir, file))
+}
+
+// logf writes a log line to stderr if the level is enabled
+func logf(level int, format string, args ...interface{}) {
+	if logLevel >= level {
+		fmt.Fprintf(os.Stderr, format+"\n", args...)
+	}
+}
+
+// Debugf writes a note to stderr when --debug is enabled
+func Debugf(format string, args ...interface{}) {
+	logf(logDebug, format, args...)
+}
+
+// logRequest logs an outgoing request
+func logRequest(req *http.Request) {
+	logf(logVerbose, "--> %s %s", req.Method, req.URL)
+	if logLevel < logDebug {
+		return
+	}
+	for name, values := range req.Header {
+		value := strings.Join(values, ", ")
+		if name == "Authorization" {
+			value = "[REDACTED]"
+		}
+		logf(logDebug, "    %s: %s", name, value)
+	}
+}
+
+// logResponse logs a response status and, in debug mode, its body
+func logResponse(resp *http.Response, body []byte) {
+	logf(logVerbose, "<-- %d %s", resp.StatusCode, resp.Request.URL)
+	if len(body) > 0 {
+		logf(logDebug, "%s", body)
+	}
+}
+
 // Client represents a Microsoft Graph API client
 type Client struct {
 	Token string
+
+	// BaseURL is the versioned API root, e.g. https://graph.microsoft.com/v1.0
+	BaseURL string
+
+	// Refresh, if set, is called once on HTTP 401 to obtain a new token
+	// before the request is retried
+	Refresh func() (string, error)
+
+	// Context, if set, cancels in-flight requests when done
+	Context context.Context
+
+	// User, if set, is the id or userPrincipalName whose mailbox is used
+	// instead of /me, as required by app-only tokens
+	User string
+
+	stats Stats
+}
+
+// Stats counts the HTTP traffic of a Client
+type Stats struct {
+	Requests  int `json:"requests"`
+	Retries   int `json:"retries"`
+	Throttled int `json:"throttled"` // HTTP 429 responses
+}
+
+// Stats returns the request counters accumulated so far
+func (c *Client) Stats() Stats {
+	return c.stats
 }
 
 // NewClient creates a new Graph API client
 func NewClient(token string) *Client {
-	return &Client{Token: token}
+	return &Client{Token: token, BaseURL: VersionURL(DefaultVersion)}
+}
+
+// userPath returns the path of the mailbox owner: /me or /users/{User}
+func (c *Client) userPath() string {
+	if c.User == "" {
+		return "/me"
+	}
+	return "/users/" + neturl.PathEscape(c.User)
+}
+
+// VersionURL returns the API root for a Graph version ("v1.0" or "beta")
+func VersionURL(version string) string {
+	if version == "" {
+		version = DefaultVersion
+	}
+	return graphRoot + "/" + version
 }
 
 // Event represents a calendar event
 type Event struct {
-	ID                   string         `json:"id,omitempty"`
-	Subject              string         `json:"subject"`
-	Start                DateTime       `json:"start"`
-	End                  DateTime       `json:"end"`
-	IsAllDay             bool           `json:"isAllDay,omitempty"`
-	Location             *Location      `json:"location,omitempty"`
-	Organizer            *Organizer     `json:"organizer,omitempty"`
-	Attendees            []Attendee     `json:"attendees,omitempty"`
-	ResponseStatus       *Response      `json:"responseStatus,omitempty"`
-	IsOnlineMeeting      bool           `json:"isOnlineMeeting,omitempty"`
-	OnlineMeeting        *OnlineMeeting `json:"onlineMeeting,omitempty"`
-	Categories           []string       `json:"categories,omitempty"`
-	Sensitivity          string         `json:"sensitivity,omitempty"`
-	LastModifiedDateTime string         `json:"lastModifiedDateTime,omitempty"`
-	Body                 *Body          `json:"body,omitempty"`
+	ID                   string               `json:"id,omitempty"`
+	Subject              string               `json:"subject"`
+	Start                DateTime             `json:"start"`
+	End                  DateTime             `json:"end"`
+	IsAllDay             bool                 `json:"isAllDay,omitempty"`
+	Location             *Location            `json:"location,omitempty"`
+	Organizer            *Organizer           `json:"organizer,omitempty"`
+	Attendees            []Attendee           `json:"attendees,omitempty"`
+	ResponseStatus       *Response            `json:"responseStatus,omitempty"`
+	IsOnlineMeeting      bool                 `json:"isOnlineMeeting,omitempty"`
+	OnlineMeeting        *OnlineMeeting       `json:"onlineMeeting,omitempty"`
+	Categories           []string             `json:"categories,omitempty"`
+	Sensitivity          string               `json:"sensitivity,omitempty"`
+	LastModifiedDateTime string               `json:"lastModifiedDateTime,omitempty"`
+	Body                 *Body                `json:"body,omitempty"`
+	WebLink              string               `json:"webLink,omitempty"`
+	IsOrganizer          bool                 `json:"isOrganizer,omitempty"`
+	Recurrence           *PatternedRecurrence `json:"recurrence,omitempty"`
+	TransactionID        string               `json:"transactionId,omitempty"`
+	CreatedDateTime      string               `json:"createdDateTime,omitempty"`
+}
+
+// PatternedRecurrence describes how and until when an event series repeats
+type PatternedRecurrence struct {
+	Pattern RecurrencePattern `json:"pattern"`
+	Range   RecurrenceRange   `json:"range"`
+}
+
+// RecurrencePattern is the frequency of an event series
+type RecurrencePattern struct {
+	Type       string   `json:"type"` // daily, weekly, absoluteMonthly, absoluteYearly
+	Interval   int      `json:"interval"`
+	DaysOfWeek []string `json:"daysOfWeek,omitempty"`
+	DayOfMonth int      `json:"dayOfMonth,omitempty"`
+	Month      int      `json:"month,omitempty"`
+}
+
+// RecurrenceRange is the duration of an event series
+type RecurrenceRange struct {
+	Type                string `json:"type"` // noEnd, endDate or numbered
+	StartDate           string `json:"startDate"`
+	EndDate             string `json:"endDate,omitempty"`
+	NumberOfOccurrences int    `json:"numberOfOccurrences,omitempty"`
+	RecurrenceTimeZone  string `json:"recurrenceTimeZone,omitempty"`
 }
 
 // DateTime represents a date/time
@@ -63,6 +274,8 @@ type Organizer struct {
 // Attendee represents an attendee
 type Attendee struct {
 	EmailAddress EmailAddress `json:"emailAddress"`
+	Type         string       `json:"type,omitempty"` // required, optional, resource
+	Status       *Response    `json:"status,omitempty"`
 }
 
 // EmailAddress represents an email address
@@ -79,6 +292,16 @@ func (e EmailAddress) Format() string {
 	return e.Address
 }
 
+// ParseRecipient parses "email" or "Name <email>" and rejects malformed
+// addresses such as "user@@example.com"
+func ParseRecipient(s string) (EmailAddress, error) {
+	addr, err := mail.ParseAddress(strings.TrimSpace(s))
+	if err != nil {
+		return EmailAddress{}, fmt.Errorf("invalid email address %q: %v", s, strings.TrimPrefix(err.Error(), "mail: "))
+	}
+	return EmailAddress{Name: addr.Name, Address: addr.Address}, nil
+}
+
 // Response represents a response status
 type Response struct {
 	Response string `json:"response"`
@@ -112,6 +335,38 @@ type Contact struct {
 	Removed              *RemovedMarker `json:"@removed,omitempty"`
 }
 
+// User represents the signed-in user
+type User struct {
+	ID                string `json:"id"`
+	DisplayName       string `json:"displayName"`
+	UserPrincipalName string `json:"userPrincipalName"`
+	Mail              string `json:"mail"`
+}
+
+// ScheduleInformation represents the free/busy information of one schedule
+type ScheduleInformation struct {
+	ScheduleID       string         `json:"scheduleId"`
+	AvailabilityView string         `json:"availabilityView"`
+	ScheduleItems    []ScheduleItem `json:"scheduleItems"`
+	Error            *ScheduleError `json:"error,omitempty"`
+}
+
+// ScheduleItem represents a busy block in a schedule
+type ScheduleItem struct {
+	Status    string   `json:"status"` // free, tentative, busy, oof, workingElsewhere, unknown
+	Subject   string   `json:"subject"`
+	Location  string   `json:"location"`
+	IsPrivate bool     `json:"isPrivate"`
+	Start     DateTime `json:"start"`
+	End       DateTime `json:"end"`
+}
+
+// ScheduleError represents a per-schedule error in a getSchedule response
+type ScheduleError struct {
+	Message      string `json:"message"`
+	ResponseCode string `json:"responseCode"`
+}
+
 // RemovedMarker indicates a removed item in delta query
 type RemovedMarker struct {
 	Reason string `json:"reason"`
@@ -122,23 +377,118 @@ type ODataResponse struct {
 	Value    json.RawMessage `json:"value"`
 	NextLink string          `json:"@odata.nextLink"`
 	DeltaLink string         `json:"@odata.deltaLink"`
+	Count    int             `json:"@odata.count"`
+}
+
+// BatchRequest represents a single request in a $batch call.
+// URL is relative to the API version, e.g. "/me/events/{id}".
+type BatchRequest struct {
+	ID      string            `json:"id"`
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// BatchResponse represents the response to a single request in a $batch call
+type BatchResponse struct {
+	ID      string            `json:"id"`
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// Err returns an APIError if the batched request failed
+func (r BatchResponse) Err() error {
+	if r.Status < 400 {
+		return nil
+	}
+	return parseAPIError("API error", r.Status, r.Headers["request-id"], r.Headers["client-request-id"], r.Body)
 }
 
 // ErrorResponse represents an error from the Graph API
 type ErrorResponse struct {
 	Error struct {
-		Code    string `json:"code"`
-		Message string `json:"message"`
+		Code       string `json:"code"`
+		Message    string `json:"message"`
+		InnerError struct {
+			RequestID       string `json:"request-id"`
+			ClientRequestID string `json:"client-request-id"`
+		} `json:"innerError"`
 	} `json:"error"`
 }
 
+// APIError is returned for Graph API responses with an error status
+type APIError struct {
+	Op              string // e.g. "API error", "failed to delete event"
+	StatusCode      int
+	Code            string
+	Message         string
+	RequestID       string
+	ClientRequestID string
+}
+
+// Error formats the status, Graph error code, message and request ids
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s (HTTP %d", e.Op, e.StatusCode)
+	if e.Code != "" {
+		msg += ", " + e.Code
+	}
+	msg += ")"
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+
+	var ids []string
+	if e.RequestID != "" {
+		ids = append(ids, "request-id: "+e.RequestID)
+	}
+	if e.ClientRequestID != "" {
+		ids = append(ids, "client-request-id: "+e.ClientRequestID)
+	}
+	if len(ids) > 0 {
+		msg += " [" + strings.Join(ids, ", ") + "]"
+	}
+	return msg
+}
+
+// newAPIError builds an APIError from an error response, preferring the request-id headers
+func newAPIError(op string, resp *http.Response, body []byte) *APIError {
+	return parseAPIError(op, resp.StatusCode, resp.Header.Get("request-id"), resp.Header.Get("client-request-id"), body)
+}
+
+// parseAPIError builds an APIError from a status, request ids and error body
+func parseAPIError(op string, statusCode int, requestID, clientRequestID string, body []byte) *APIError {
+	apiErr := &APIError{
+		Op:              op,
+		StatusCode:      statusCode,
+		RequestID:       requestID,
+		ClientRequestID: clientRequestID,
+	}
+
+	var errResp ErrorResponse
+	if json.Unmarshal(body, &errResp) == nil {
+		apiErr.Code = errResp.Error.Code
+		apiErr.Message = errResp.Error.Message
+		if apiErr.RequestID == "" {
+			apiErr.RequestID = errResp.Error.InnerError.RequestID
+		}
+		if apiErr.ClientRequestID == "" {
+			apiErr.ClientRequestID = errResp.Error.InnerError.ClientRequestID
+		}
+	}
+
+	return apiErr
+}
+
 // GetCalendarView retrieves calendar events in a date range
 func (c *Client) GetCalendarView(startDate, endDate time.Time) ([]Event, error) {
 	// Format dates in their current timezone (don't convert to UTC)
 	start := startDate.Format("2006-01-02T15:04:05")
 	end := endDate.Format("2006-01-02T15:04:05")
 
-	url := fmt.Sprintf("%s/me/calendarview?startDateTime=%s&endDateTime=%s", baseURL, start, end)
+	url := fmt.Sprintf("%s%s/calendarview?startDateTime=%s&endDateTime=%s&$top=%d&$count=true&$select=%s",
+		c.BaseURL, c.userPath(), start, end, calendarPageSize, eventSelectFields)
 
 	var allEvents []Event
 
@@ -158,6 +508,11 @@ func (c *Client) GetCalendarView(startDate, endDate time.Time) ([]Event, error)
 			return nil, fmt.Errorf("failed to parse events: %w", err)
 		}
 
+		// The first page carries the total count when $count=true
+		if allEvents == nil && odataResp.Count > 0 {
+			allEvents = make([]Event, 0, odataResp.Count)
+		}
+
 		allEvents = append(allEvents, events...)
 		url = odataResp.NextLink
 	}
@@ -166,29 +521,33 @@ func (c *Client) GetCalendarView(startDate, endDate time.Time) ([]Event, error)
 }
 
 // GetContactsDelta retrieves contacts using delta query
+//
+// If a page fails after retries, the contacts fetched so far are returned
+// together with the link of the failed page and the error. Passing that
+// link as deltaLink on the next call resumes from there.
 func (c *Client) GetContactsDelta(deltaLink string) ([]Contact, string, error) {
 	url := deltaLink
 	if url == "" {
-		url = fmt.Sprintf("%s/me/contacts/delta", baseURL)
+		url = fmt.Sprintf("%s%s/contacts/delta?$select=%s", c.BaseURL, c.userPath(), contactSelectFields)
 	}
 
 	var allContacts []Contact
 	var newDeltaLink string
 
 	for url != "" {
-		resp, err := c.doRequest("GET", url, nil)
+		resp, err := c.getWithRetry(url)
 		if err != nil {
-			return nil, "", err
+			return allContacts, url, err
 		}
 
 		var odataResp ODataResponse
 		if err := json.Unmarshal(resp, &odataResp); err != nil {
-			return nil, "", fmt.Errorf("failed to parse response: %w", err)
+			return allContacts, url, fmt.Errorf("failed to parse response: %w", err)
 		}
 
 		var contacts []Contact
 		if err := json.Unmarshal(odataResp.Value, &contacts); err != nil {
-			return nil, "", fmt.Errorf("failed to parse contacts: %w", err)
+			return allContacts, url, fmt.Errorf("failed to parse contacts: %w", err)
 		}
 
 		allContacts = append(allContacts, contacts...)
@@ -203,9 +562,71 @@ func (c *Client) GetContactsDelta(deltaLink string) ([]Contact, string, error) {
 	return allContacts, newDeltaLink, nil
 }
 
+// getWithRetry performs a GET, retrying transient failures (network errors,
+// HTTP 429 and 5xx) with a short backoff up to deltaMaxAttempts times
+func (c *Client) getWithRetry(url string) ([]byte, error) {
+	var lastErr error
+	for attempt := 1; attempt <= deltaMaxAttempts; attempt++ {
+		resp, err := c.doRequest("GET", url, nil)
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+
+		if !isTransient(err) || attempt == deltaMaxAttempts {
+			break
+		}
+
+		backoff := time.Duration(attempt) * 2 * time.Second
+		logf(logVerbose, "    retrying in %s after: %v", backoff, err)
+		c.stats.Retries++
+
+		ctx := c.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return nil, lastErr
+}
+
+// IsResyncRequired reports whether a delta query failed because Graph no
+// longer accepts the stored delta link and the delta must start over
+func IsResyncRequired(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	switch apiErr.Code {
+	case "syncStateNotFound", "syncStateInvalid", "resyncRequired":
+		return true
+	}
+	return apiErr.StatusCode == http.StatusGone
+}
+
+// isTransient reports whether a request error is worth retrying
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+
+	// Network-level failure
+	return true
+}
+
 // CreateEvent creates a new calendar event
 func (c *Client) CreateEvent(event *Event) (*Event, error) {
-	url := fmt.Sprintf("%s/me/events", baseURL)
+	url := fmt.Sprintf("%s%s/events", c.BaseURL, c.userPath())
 
 	data, err := json.Marshal(event)
 	if err != nil {
@@ -225,56 +646,244 @@ func (c *Client) CreateEvent(event *Event) (*Event, error) {
 	return &created, nil
 }
 
+// GetEvent retrieves a single calendar event
+func (c *Client) GetEvent(eventID string) (*Event, error) {
+	url := fmt.Sprintf("%s%s/events/%s?$select=%s", c.BaseURL, c.userPath(), eventID, eventSelectFields)
+
+	resp, err := c.doRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var event Event
+	if err := json.Unmarshal(resp, &event); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &event, nil
+}
+
+// UpdateEvent patches the given fields of a calendar event and returns the updated event
+func (c *Client) UpdateEvent(eventID string, fields map[string]interface{}) (*Event, error) {
+	url := fmt.Sprintf("%s%s/events/%s", c.BaseURL, c.userPath(), eventID)
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	resp, err := c.doRequest("PATCH", url, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var updated Event
+	if err := json.Unmarshal(resp, &updated); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &updated, nil
+}
+
 // DeleteEvent deletes a calendar event
 func (c *Client) DeleteEvent(eventID string) error {
-	url := fmt.Sprintf("%s/me/events/%s", baseURL, eventID)
+	url := fmt.Sprintf("%s%s/events/%s", c.BaseURL, c.userPath(), eventID)
 
-	req, err := http.NewRequest("DELETE", url, nil)
+	resp, body, err := c.send("DELETE", url, nil)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.Token)
+	if resp.StatusCode != http.StatusNoContent {
+		return newAPIError("failed to delete event", resp, body)
+	}
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	return nil
+}
+
+// CancelEvent cancels a meeting the user organizes, sending a cancellation
+// with the optional comment to all attendees, and removes it from the calendar
+func (c *Client) CancelEvent(eventID, comment string) error {
+	url := fmt.Sprintf("%s%s/events/%s/cancel", c.BaseURL, c.userPath(), eventID)
+
+	data, err := json.Marshal(map[string]string{"comment": comment})
 	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
+		return fmt.Errorf("failed to marshal payload: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusNoContent {
-		body, _ := io.ReadAll(resp.Body)
-		var errResp ErrorResponse
-		if json.Unmarshal(body, &errResp) == nil && errResp.Error.Message != "" {
-			return fmt.Errorf("failed to delete event (HTTP %d): %s", resp.StatusCode, errResp.Error.Message)
+	_, err = c.doRequest("POST", url, data)
+	return err
+}
+
+// DeleteEvents deletes several calendar events using $batch and returns
+// the per-event errors keyed by event ID
+func (c *Client) DeleteEvents(eventIDs []string) (map[string]error, error) {
+	requests := make([]BatchRequest, len(eventIDs))
+	for i, id := range eventIDs {
+		requests[i] = BatchRequest{
+			ID:     id,
+			Method: "DELETE",
+			URL:    c.userPath() + "/events/" + id,
 		}
-		return fmt.Errorf("failed to delete event (HTTP %d)", resp.StatusCode)
 	}
 
-	return nil
+	responses, err := c.Batch(requests)
+	if err != nil {
+		return nil, err
+	}
+
+	failed := make(map[string]error)
+	for _, resp := range responses {
+		if err := resp.Err(); err != nil {
+			failed[resp.ID] = err
+		}
+	}
+	return failed, nil
+}
+
+// Batch sends requests via $batch, chunked to the Graph limit of 20 per call.
+// Responses are returned in request order; per-item failures are reported
+// through BatchResponse.Err rather than the returned error.
+func (c *Client) Batch(requests []BatchRequest) ([]BatchResponse, error) {
+	url := fmt.Sprintf("%s/$batch", c.BaseURL)
+
+	responses := make([]BatchResponse, 0, len(requests))
+	for start := 0; start < len(requests); start += maxBatchSize {
+		end := start + maxBatchSize
+		if end > len(requests) {
+			end = len(requests)
+		}
+		chunk := requests[start:end]
+
+		// Requests with a body must declare its content type
+		for i := range chunk {
+			if len(chunk[i].Body) > 0 && chunk[i].Headers == nil {
+				chunk[i].Headers = map[string]string{"Content-Type": "application/json"}
+			}
+		}
+
+		data, err := json.Marshal(map[string]interface{}{"requests": chunk})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal batch: %w", err)
+		}
+
+		resp, err := c.doRequest("POST", url, data)
+		if err != nil {
+			return nil, err
+		}
+
+		var batchResp struct {
+			Responses []BatchResponse `json:"responses"`
+		}
+		if err := json.Unmarshal(resp, &batchResp); err != nil {
+			return nil, fmt.Errorf("failed to parse batch response: %w", err)
+		}
+
+		// Graph may answer out of order; restore request order
+		byID := make(map[string]BatchResponse, len(batchResp.Responses))
+		for _, r := range batchResp.Responses {
+			byID[r.ID] = r
+		}
+		for _, req := range chunk {
+			r, ok := byID[req.ID]
+			if !ok {
+				return nil, fmt.Errorf("missing batch response for request %s", req.ID)
+			}
+			responses = append(responses, r)
+		}
+	}
+
+	return responses, nil
 }
 
-// SendMail sends an email
-func (c *Client) SendMail(to, subject, body string) error {
-	url := fmt.Sprintf("%s/me/sendMail", baseURL)
+// GetMe retrieves the signed-in user (requires User.Read)
+func (c *Client) GetMe() (*User, error) {
+	url := fmt.Sprintf("%s%s?$select=id,displayName,userPrincipalName,mail", c.BaseURL, c.userPath())
+
+	resp, err := c.doRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var user User
+	if err := json.Unmarshal(resp, &user); err != nil {
+		return nil, fmt.Errorf("failed to parse user: %w", err)
+	}
+
+	return &user, nil
+}
+
+// GetSchedule retrieves free/busy information for the given addresses.
+// start and end are sent in their own location, which must be an IANA zone name.
+func (c *Client) GetSchedule(emails []string, start, end time.Time, intervalMinutes int) ([]ScheduleInformation, error) {
+	url := fmt.Sprintf("%s%s/calendar/getSchedule", c.BaseURL, c.userPath())
 
 	payload := map[string]interface{}{
-		"message": map[string]interface{}{
-			"subject": subject,
-			"body": map[string]string{
-				"contentType": "text",
-				"content":     body,
-			},
-			"toRecipients": []map[string]interface{}{
-				{
-					"emailAddress": map[string]string{
-						"address": to,
-					},
+		"schedules": emails,
+		"startTime": DateTime{
+			DateTime: start.Format("2006-01-02T15:04:05"),
+			TimeZone: start.Location().String(),
+		},
+		"endTime": DateTime{
+			DateTime: end.Format("2006-01-02T15:04:05"),
+			TimeZone: end.Location().String(),
+		},
+		"availabilityViewInterval": intervalMinutes,
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	resp, err := c.doRequest("POST", url, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var odataResp ODataResponse
+	if err := json.Unmarshal(resp, &odataResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	var schedules []ScheduleInformation
+	if err := json.Unmarshal(odataResp.Value, &schedules); err != nil {
+		return nil, fmt.Errorf("failed to parse schedules: %w", err)
+	}
+
+	return schedules, nil
+}
+
+// MailMessage builds the Graph message resource sent by SendMail
+func MailMessage(to, subject, body string) map[string]interface{} {
+	return map[string]interface{}{
+		"subject": subject,
+		"body": map[string]string{
+			"contentType": "text",
+			"content":     body,
+		},
+		"toRecipients": []map[string]interface{}{
+			{
+				"emailAddress": map[string]string{
+					"address": to,
 				},
 			},
 		},
 	}
+}
+
+// SendMail sends an email from the signed-in user, or from the mailbox
+// from if set (needs Mail.Send.Shared and send-as rights on it)
+func (c *Client) SendMail(from, to, subject, body string) error {
+	url := fmt.Sprintf("%s%s/sendMail", c.BaseURL, c.userPath())
+	if from != "" {
+		// Send as a shared or delegated mailbox
+		url = fmt.Sprintf("%s/users/%s/sendMail", c.BaseURL, neturl.PathEscape(from))
+	}
+
+	payload := map[string]interface{}{
+		"message": MailMessage(to, subject, body),
+	}
 
 	data, err := json.Marshal(payload)
 	if err != nil {
@@ -287,14 +896,57 @@ func (c *Client) SendMail(to, subject, body string) error {
 
 // doRequest performs an HTTP request
 func (c *Client) doRequest(method, url string, body []byte) ([]byte, error) {
+	resp, respBody, err := c.send(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check for errors
+	if resp.StatusCode >= 400 {
+		return nil, newAPIError("API error", resp, respBody)
+	}
+
+	// For methods that return no content
+	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusAccepted {
+		return nil, nil
+	}
+
+	return respBody, nil
+}
+
+// send performs an HTTP request and returns the response with its body read.
+// On HTTP 401 the token is refreshed once via Refresh and the request retried.
+func (c *Client) send(method, url string, body []byte) (*http.Response, []byte, error) {
+	resp, respBody, err := c.sendOnce(method, url, body)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || c.Refresh == nil {
+		return resp, respBody, err
+	}
+
+	token, err := c.Refresh()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to refresh token after HTTP 401: %w", err)
+	}
+	c.Token = token
+	c.stats.Retries++
+
+	return c.sendOnce(method, url, body)
+}
+
+// sendOnce performs a single HTTP request
+func (c *Client) sendOnce(method, url string, body []byte) (*http.Response, []byte, error) {
 	var reqBody io.Reader
 	if body != nil {
 		reqBody = bytes.NewReader(body)
 	}
 
-	req, err := http.NewRequest(method, url, reqBody)
+	ctx := c.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Authorization", "Bearer "+c.Token)
@@ -302,33 +954,75 @@ func (c *Client) doRequest(method, url string, body []byte) ([]byte, error) {
 		req.Header.Set("Content-Type", "application/json")
 	}
 
+	logRequest(req)
+	c.stats.Requests++
+
 	client := &http.Client{Timeout: 30 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusTooManyRequests {
+		c.stats.Throttled++
+	}
+
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, nil, fmt.Errorf("failed to read response: %w", err)
 	}
+	logResponse(resp, respBody)
+	dumpResponse(req, resp, respBody)
 
-	// Check for errors
-	if resp.StatusCode >= 400 {
-		var errResp ErrorResponse
-		if json.Unmarshal(respBody, &errResp) == nil && errResp.Error.Message != "" {
-			return nil, fmt.Errorf("API error (HTTP %d): %s", resp.StatusCode, errResp.Error.Message)
-		}
-		return nil, fmt.Errorf("API error (HTTP %d)", resp.StatusCode)
+	return resp, respBody, nil
+}
+
+// maxDataURIBytes is the largest data: image URI kept in converted bodies
+const maxDataURIBytes = 2048
+
+var (
+	imgTagRe = regexp.MustCompile(`(?i)<img\b[^>]*>`)
+	pixelRe  = regexp.MustCompile(`(?i)\b(width|height)\s*:\s*[01]px`)
+	imgAttrs = map[string]*regexp.Regexp{}
+)
+
+func init() {
+	for _, name := range []string{"src", "alt", "width", "height", "style"} {
+		imgAttrs[name] = regexp.MustCompile(`(?is)\s` + name + `\s*=\s*(?:"([^"]*)"|'([^']*)')`)
 	}
+}
 
-	// For methods that return no content
-	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusAccepted {
-		return nil, nil
+// imgAttr returns the value of a quoted src, alt, width, height or style
+// attribute of an <img> tag
+func imgAttr(tag, name string) string {
+	m := imgAttrs[name].FindStringSubmatch(tag)
+	if m == nil {
+		return ""
 	}
+	return m[1] + m[2]
+}
 
-	return respBody, nil
+// convertImage turns an <img> tag into a markdown image, or drops it if it
+// is a 1x1 tracking pixel or an inline data: URI over maxDataURIBytes
+func convertImage(tag string) string {
+	src := strings.TrimSpace(imgAttr(tag, "src"))
+	if src == "" {
+		return ""
+	}
+	if strings.HasPrefix(strings.ToLower(src), "data:") && len(src) > maxDataURIBytes {
+		return ""
+	}
+
+	width, height := imgAttr(tag, "width"), imgAttr(tag, "height")
+	if (width == "0" || width == "1") && (height == "0" || height == "1") {
+		return ""
+	}
+	if len(pixelRe.FindAllString(imgAttr(tag, "style"), -1)) >= 2 {
+		return ""
+	}
+
+	return fmt.Sprintf("![%s](%s)", imgAttr(tag, "alt"), src)
 }
 
 // HTMLToMarkdown converts HTML to basic markdown
@@ -356,6 +1050,9 @@ func HTMLToMarkdown(html string) string {
 	md = regexp.MustCompile(`<em>([^<]*)</em>`).ReplaceAllString(md, "*$1*")
 	md = regexp.MustCompile(`<i>([^<]*)</i>`).ReplaceAllString(md, "*$1*")
 
+	// Convert images, dropping tracking pixels and large inline data
+	md = imgTagRe.ReplaceAllStringFunc(md, convertImage)
+
 	// Remove all remaining HTML tags
 	md = regexp.MustCompile(`<[^>]*>`).ReplaceAllString(md, "")
 
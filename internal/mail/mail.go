@@ -1,15 +1,34 @@
 package mail
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
 
 	"github.com/lcorneliussen/md365/internal/auth"
 	"github.com/lcorneliussen/md365/internal/config"
 	"github.com/lcorneliussen/md365/internal/graph"
+	"github.com/lcorneliussen/md365/internal/output"
 )
 
-// Send sends an email
-func Send(cfg *config.Config, account, to, subject, body string, force bool) error {
+// Send sends an email, from the mailbox from instead of the signed-in user
+// if set. With dryRun, the message is checked and printed but not sent.
+func Send(cfg *config.Config, account, from, to, subject, body string, force, dryRun bool) error {
+	// Validate the recipient before the cross-tenant check and any API call
+	recipient, err := graph.ParseRecipient(to)
+	if err != nil {
+		return err
+	}
+	to = recipient.Address
+
+	if from != "" {
+		sender, err := graph.ParseRecipient(from)
+		if err != nil {
+			return fmt.Errorf("invalid --from: %w", err)
+		}
+		from = sender.Address
+	}
+
 	// Check cross-tenant unless force is enabled
 	if !force {
 		if err := cfg.CheckCrossTenant(account, []string{to}); err != nil {
@@ -17,18 +36,56 @@ func Send(cfg *config.Config, account, to, subject, body string, force bool) err
 		}
 	}
 
-	// Get access token
-	token, err := auth.GetAccessToken(cfg, account)
+	if dryRun {
+		return printDryRun(cfg, account, from, to, subject, body)
+	}
+
+	// Get Graph client
+	client, err := auth.NewGraphClient(cfg, account)
 	if err != nil {
 		return err
 	}
 
 	// Send email
-	client := graph.NewClient(token)
-	if err := client.SendMail(to, subject, body); err != nil {
+	if err := client.SendMail(from, to, subject, body); err != nil {
+		var apiErr *graph.APIError
+		if from != "" && errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusForbidden {
+			return fmt.Errorf("account '%s' may not send as %s (needs the Mail.Send.Shared scope and send-as or send-on-behalf rights on the mailbox): %w", account, from, err)
+		}
 		return err
 	}
 
-	fmt.Printf("Email sent to %s\n", to)
+	output.Infof("Email sent to %s\n", to)
+	return nil
+}
+
+// printDryRun prints the message that would be sent
+func printDryRun(cfg *config.Config, account, from, to, subject, body string) error {
+	acc, err := cfg.GetAccount(account)
+	if err != nil {
+		return err
+	}
+	if from == "" {
+		from = acc.Hint
+	}
+
+	if output.JSON() {
+		return output.PrintJSON(map[string]interface{}{
+			"account": account,
+			"from":    from,
+			"message": graph.MailMessage(to, subject, body),
+		})
+	}
+
+	fmt.Println("Dry run: message not sent")
+	fmt.Println()
+	fmt.Printf("Account: %s\n", account)
+	if from != "" {
+		fmt.Printf("From:    %s\n", from)
+	}
+	fmt.Printf("To:      %s\n", to)
+	fmt.Printf("Subject: %s\n", subject)
+	fmt.Println()
+	fmt.Println(body)
 	return nil
 }
@@ -0,0 +1,180 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/mattn/go-isatty"
+)
+
+const (
+	// FormatText is the default human-readable output
+	FormatText = "text"
+	// FormatJSON is machine-readable JSON output
+	FormatJSON = "json"
+)
+
+// Color modes
+const (
+	ColorAuto   = "auto"   // Color on a terminal unless NO_COLOR is set
+	ColorAlways = "always" // Always color
+	ColorNever  = "never"  // Never color
+)
+
+// ANSI color codes
+const (
+	ansiReset  = "\033[0m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+	ansiCyan   = "\033[36m"
+)
+
+var (
+	format = FormatText
+	quiet  bool
+	color  bool
+)
+
+// SetFormat sets the output format for all commands
+func SetFormat(f string) error {
+	switch f {
+	case "", FormatText:
+		format = FormatText
+	case FormatJSON:
+		format = FormatJSON
+	default:
+		return fmt.Errorf("invalid output format '%s'. Valid values: text, json", f)
+	}
+	return nil
+}
+
+// JSON reports whether JSON output was requested
+func JSON() bool {
+	return format == FormatJSON
+}
+
+// SetQuiet suppresses informational output printed via Infof and Progressf
+func SetQuiet(q bool) {
+	quiet = q
+}
+
+// Quiet reports whether informational output is suppressed
+func Quiet() bool {
+	return quiet
+}
+
+// Infof prints an informational message, such as a confirmation, to stdout
+// unless quiet
+func Infof(msg string, args ...interface{}) {
+	if !quiet {
+		fmt.Printf(msg, args...)
+	}
+}
+
+// Progressf prints a progress message to stderr unless quiet
+func Progressf(msg string, args ...interface{}) {
+	if !quiet {
+		fmt.Fprintf(os.Stderr, msg, args...)
+	}
+}
+
+// Limit truncates items to the first limit entries (0 = no limit). The
+// returned func prints "(showing N of M)" to stderr if entries were dropped,
+// so JSON and template output stay clean; defer it to print after the list.
+func Limit[T any](items []T, limit int) ([]T, func()) {
+	total := len(items)
+	if limit <= 0 || total <= limit {
+		return items, func() {}
+	}
+	return items[:limit], func() {
+		Progressf("(showing %d of %d)\n", limit, total)
+	}
+}
+
+// SetColor sets the color mode for text output
+func SetColor(mode string) error {
+	switch mode {
+	case "", ColorAuto:
+		_, noColor := os.LookupEnv("NO_COLOR")
+		color = !noColor && os.Getenv("TERM") != "dumb" && isatty.IsTerminal(os.Stdout.Fd())
+	case ColorAlways:
+		color = true
+	case ColorNever:
+		color = false
+	default:
+		return fmt.Errorf("invalid color mode '%s'. Valid values: auto, always, never", mode)
+	}
+	return nil
+}
+
+// colorize wraps s in an ANSI color if color output is enabled
+func colorize(code, s string) string {
+	if !color {
+		return s
+	}
+	return code + s + ansiReset
+}
+
+// Green colors s green, e.g. for valid states
+func Green(s string) string { return colorize(ansiGreen, s) }
+
+// Red colors s red, e.g. for expired or failed states
+func Red(s string) string { return colorize(ansiRed, s) }
+
+// Yellow colors s yellow, e.g. for warnings
+func Yellow(s string) string { return colorize(ansiYellow, s) }
+
+// Cyan colors s cyan, e.g. to highlight today's events
+func Cyan(s string) string { return colorize(ansiCyan, s) }
+
+// Template parses a user-supplied --format template and executes it once
+// against sample, so unknown fields are reported before any output.
+// Templates can use join, e.g. {{join .Emails ", "}}.
+func Template(text string, sample interface{}) (*template.Template, error) {
+	tmpl, err := template.New("format").Funcs(template.FuncMap{"join": strings.Join}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --format template: %w", err)
+	}
+	if err := tmpl.Execute(io.Discard, sample); err != nil {
+		return nil, fmt.Errorf("invalid --format template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// Matcher returns a case-insensitive content matcher for a --search query,
+// compiling it as a regular expression if useRegex is set. An empty query
+// matches everything.
+func Matcher(query string, useRegex bool) (func(string) bool, error) {
+	if query == "" {
+		return func(string) bool { return true }, nil
+	}
+
+	if useRegex {
+		re, err := regexp.Compile("(?i)" + query)
+		if err != nil {
+			return nil, fmt.Errorf("invalid search pattern: %w", err)
+		}
+		return re.MatchString, nil
+	}
+
+	queryLower := strings.ToLower(query)
+	return func(content string) bool {
+		return strings.Contains(strings.ToLower(content), queryLower)
+	}, nil
+}
+
+// PrintJSON writes v as indented JSON to stdout
+func PrintJSON(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal output: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
@@ -0,0 +1,155 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lcorneliussen/md365/internal/auth"
+	"github.com/lcorneliussen/md365/internal/config"
+	"github.com/lcorneliussen/md365/internal/graph"
+	"gopkg.in/yaml.v3"
+)
+
+// migrations upgrade frontmatter from schema version i to i+1
+var migrations = []func(fm map[string]interface{}, account string){
+	// 0 -> 1: add the generator marker and the account if missing, and turn
+	// legacy "Name <email>" attendee strings into {email, name} maps
+	func(fm map[string]interface{}, account string) {
+		fm["generator"] = Generator
+		if _, ok := fm["account"]; !ok {
+			fm["account"] = account
+		}
+		if list, ok := fm["attendees"].([]interface{}); ok {
+			fm["attendees"] = migrateAttendees(list)
+			fm["attendee_count"] = len(list)
+		}
+	},
+}
+
+// migrateAttendees converts legacy attendee strings to the map form written
+// by sync; entries that already are maps are kept
+func migrateAttendees(list []interface{}) []interface{} {
+	attendees := make([]interface{}, len(list))
+	for i, a := range list {
+		s, ok := a.(string)
+		if !ok {
+			attendees[i] = a
+			continue
+		}
+		recipient, err := graph.ParseRecipient(s)
+		if err != nil {
+			// Keep what was there rather than dropping an attendee
+			attendees[i] = map[string]interface{}{"email": strings.TrimSpace(s)}
+			continue
+		}
+		attendee := map[string]interface{}{"email": recipient.Address}
+		if recipient.Name != "" && recipient.Name != recipient.Address {
+			attendee["name"] = recipient.Name
+		}
+		attendees[i] = attendee
+	}
+	return attendees
+}
+
+// MigrateResult counts the files seen by Migrate
+type MigrateResult struct {
+	Migrated int `json:"migrated"`
+	Current  int `json:"current"`
+	Skipped  int `json:"skipped"` // Not md365 files, unreadable or from a newer version
+}
+
+// Migrate rewrites the frontmatter of all event and contact files of the
+// given accounts to the current schema version. Originals are kept as
+// <file>.bak and bodies are left untouched. With dryRun nothing is written.
+func Migrate(cfg *config.Config, accounts []string, dryRun bool) (*MigrateResult, error) {
+	result := &MigrateResult{}
+
+	for _, account := range accounts {
+		for _, kind := range []string{"calendar", "contacts"} {
+			dir := filepath.Join(cfg.DataDir, account, kind)
+			if _, err := os.Stat(dir); os.IsNotExist(err) {
+				continue
+			}
+
+			err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() || !strings.HasSuffix(path, ".md") {
+					return nil
+				}
+
+				migrated, err := migrateFile(path, account, dryRun)
+				switch {
+				case err != nil:
+					fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", path, err)
+					result.Skipped++
+				case migrated:
+					result.Migrated++
+				default:
+					result.Current++
+				}
+				return nil
+			})
+			if err != nil {
+				return result, fmt.Errorf("failed to walk %s: %w", dir, err)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// migrateFile upgrades one file and reports whether it needed a change
+func migrateFile(path, account string, dryRun bool) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	parts := strings.SplitN(string(data), "---", 3)
+	if len(parts) < 3 {
+		return false, fmt.Errorf("no frontmatter")
+	}
+
+	var fm map[string]interface{}
+	if err := yaml.Unmarshal([]byte(parts[1]), &fm); err != nil {
+		return false, fmt.Errorf("invalid frontmatter: %w", err)
+	}
+
+	// Files without an id were not written by md365
+	if id, _ := fm["id"].(string); id == "" {
+		return false, fmt.Errorf("no id in frontmatter, not an md365 file")
+	}
+
+	version, _ := fm["schema_version"].(int)
+	if version > SchemaVersion {
+		return false, fmt.Errorf("schema_version %d is newer than this md365 supports (%d)", version, SchemaVersion)
+	}
+	if version == SchemaVersion {
+		return false, nil
+	}
+	if dryRun {
+		return true, nil
+	}
+
+	for v := version; v < SchemaVersion; v++ {
+		migrations[v](fm, account)
+	}
+	fm["schema_version"] = SchemaVersion
+
+	fmData, err := yaml.Marshal(fm)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal frontmatter: %w", err)
+	}
+
+	if err := auth.AtomicWriteFile(path+".bak", data, 0644); err != nil {
+		return false, fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	content := fmt.Sprintf("---\n%s---%s", string(fmData), parts[2])
+	if err := auth.AtomicWriteFile(path, []byte(content), 0644); err != nil {
+		return false, fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return true, nil
+}
@@ -1,27 +1,126 @@
 package sync
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	gosync "sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/lcorneliussen/md365/internal/auth"
 	"github.com/lcorneliussen/md365/internal/config"
 	"github.com/lcorneliussen/md365/internal/graph"
+	"github.com/lcorneliussen/md365/internal/output"
 	"gopkg.in/yaml.v3"
 )
 
+// Pruning safety limits: refuse to delete more than this share of the
+// existing local files (once at least pruneMinCount files would go) unless forced
+const (
+	pruneMaxRatio = 0.5
+	pruneMinCount = 5
+)
+
+// dataGitignore keeps sync state and token material out of git when the data
+// directory is a repository
+const dataGitignore = `# Written by md365 on first sync; set data_gitignore: false to skip it
+.sync/
+tokens/
+*.bak
+`
+
+// Frontmatter markers identifying files written by md365. Files without them
+// (hand-written or from older versions) are read the same way.
+const (
+	Generator     = "md365"
+	SchemaVersion = 1
+)
+
+// Options controls sync behavior
+type Options struct {
+	Force      bool      // Bypass the mass-deletion safety check
+	AllowEmpty bool      // Prune even if Graph returned no events
+	Since      time.Time // Only sync events starting at or after this time (zero = default window)
+	SinceLast  bool      // Use the account's last sync time as Since, if there is one
+	NoPrune    bool      // Never delete local files; mark them "deleted: true" instead
+	Quiet      bool      // Suppress progress output on stdout, e.g. for --report json
+	Full       bool      // Ignore stored delta links and drop local contacts missing upstream
+}
+
+// printf prints progress output unless Quiet or the global --quiet is set
+func (o Options) printf(format string, args ...interface{}) {
+	if !o.Quiet {
+		output.Infof(format, args...)
+	}
+}
+
+// Result summarizes the calendar or contacts sync of one account
+type Result struct {
+	New     int         `json:"new"`
+	Updated int         `json:"updated"`
+	Deleted int         `json:"deleted"` // Deleted, or marked deleted with NoPrune
+	Errors  []ItemError `json:"errors,omitempty"`
+}
+
+// ItemError records a failure for a single event or contact
+type ItemError struct {
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error"`
+}
+
+// addError records a per-item failure and prints it as a warning
+func (r *Result) addError(id, what string, err error) {
+	fmt.Fprintf(os.Stderr, "Warning: failed to %s: %v\n", what, err)
+	r.Errors = append(r.Errors, ItemError{ID: id, Error: fmt.Sprintf("failed to %s: %v", what, err)})
+}
+
+// AccountReport is the machine-readable summary of syncing one account
+type AccountReport struct {
+	Account    string       `json:"account"`
+	Calendar   *Result      `json:"calendar,omitempty"`
+	Contacts   *Result      `json:"contacts,omitempty"`
+	Errors     []string     `json:"errors,omitempty"`
+	API        *graph.Stats `json:"api,omitempty"`
+	DurationMS int64        `json:"duration_ms"`
+}
+
 // SyncState represents the sync state for an account
 type SyncState struct {
 	LastSync          string `json:"last_sync"`
+	CalendarSync      string `json:"calendar_sync,omitempty"` // Last successful calendar sync, for --since-last-sync
 	ContactsDeltaLink string `json:"contacts_delta_link,omitempty"`
 }
 
+// ensureDataDir creates the data directory and, when it did not exist yet,
+// writes a .gitignore into it unless disabled in the config
+func ensureDataDir(cfg *config.Config) error {
+	if _, err := os.Stat(cfg.DataDir); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	if !cfg.GitignoreEnabled() {
+		return nil
+	}
+	if err := auth.AtomicWriteFile(filepath.Join(cfg.DataDir, ".gitignore"), []byte(dataGitignore), 0644); err != nil {
+		return fmt.Errorf("failed to write .gitignore: %w", err)
+	}
+	return nil
+}
+
 // WriteEventFile writes a calendar event to a markdown file
 func WriteEventFile(cfg *config.Config, account string, event *graph.Event, timezone string) (string, error) {
+	redacted := cfg.RedactPrivate && isPrivate(event.Sensitivity)
+	if redacted {
+		event = redactEvent(event)
+	}
+
 	calDir := filepath.Join(cfg.DataDir, account, "calendar")
 	if err := os.MkdirAll(calDir, 0755); err != nil {
 		return "", fmt.Errorf("failed to create calendar directory: %w", err)
@@ -44,26 +143,51 @@ func WriteEventFile(cfg *config.Config, account string, event *graph.Event, time
 	if slug == "" {
 		slug = "untitled"
 	}
-	desiredBase := fmt.Sprintf("%s-%s", startDate, slug)
+	desiredBase, err := cfg.EventFilename(config.FilenameData{
+		ID:      event.ID,
+		Slug:    slug,
+		Name:    event.Subject,
+		Date:    startDate,
+		Account: account,
+	})
+	if err != nil {
+		return "", err
+	}
+	if desiredBase == "" {
+		desiredBase = "untitled"
+	}
+
+	// With the monthly layout, events live in calendar/YYYY/MM/
+	targetDir := calDir
+	if cfg.CalendarLayout == config.LayoutMonthly && len(startDate) >= len("2006-01") {
+		targetDir = filepath.Join(calDir, startDate[:4], startDate[5:7])
+		if err := os.MkdirAll(targetDir, 0755); err != nil {
+			return "", fmt.Errorf("failed to create calendar directory: %w", err)
+		}
+	}
 
 	// Check if a file with this event ID already exists
 	existingPath := findFileByID(calDir, event.ID)
 
 	var filePath string
 	if existingPath != "" {
-		// Check if rename is needed (subject or date changed)
+		// Check if a move is needed (subject, date or layout changed). A
+		// "-N" suffix from an earlier name collision still matches.
 		existingBase := strings.TrimSuffix(filepath.Base(existingPath), ".md")
-		if existingBase != desiredBase {
-			newFilename := auth.GenerateUniqueFilename(calDir, desiredBase, ".md")
-			filePath = filepath.Join(calDir, newFilename)
-			os.Rename(existingPath, filePath)
+		if !matchesBase(existingBase, desiredBase) || filepath.Dir(existingPath) != targetDir {
+			newFilename := auth.GenerateUniqueFilename(targetDir, desiredBase, ".md")
+			filePath = filepath.Join(targetDir, newFilename)
+			if err := os.Rename(existingPath, filePath); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to rename %s: %v\n", existingPath, err)
+				filePath = existingPath
+			}
 		} else {
 			filePath = existingPath
 		}
 	} else {
 		// New event
-		filename := auth.GenerateUniqueFilename(calDir, desiredBase, ".md")
-		filePath = filepath.Join(calDir, filename)
+		filename := auth.GenerateUniqueFilename(targetDir, desiredBase, ".md")
+		filePath = filepath.Join(targetDir, filename)
 	}
 
 	// Build frontmatter
@@ -77,6 +201,12 @@ func WriteEventFile(cfg *config.Config, account string, event *graph.Event, time
 		"online_meeting": event.IsOnlineMeeting,
 		"sensitivity":   event.Sensitivity,
 		"last_modified": event.LastModifiedDateTime,
+		"generator":     Generator,
+		"schema_version": SchemaVersion,
+	}
+
+	if redacted {
+		fm["redacted"] = true
 	}
 
 	if event.ResponseStatus != nil {
@@ -92,11 +222,25 @@ func WriteEventFile(cfg *config.Config, account string, event *graph.Event, time
 	}
 
 	if len(event.Attendees) > 0 {
-		attendees := make([]string, len(event.Attendees))
+		attendees := make([]map[string]string, len(event.Attendees))
 		for i, a := range event.Attendees {
-			attendees[i] = a.EmailAddress.Format()
+			attendee := map[string]string{
+				"email": a.EmailAddress.Address,
+			}
+			if a.EmailAddress.Name != "" && a.EmailAddress.Name != a.EmailAddress.Address {
+				attendee["name"] = a.EmailAddress.Name
+			}
+			if a.Status != nil && a.Status.Response != "" {
+				attendee["response"] = a.Status.Response
+			}
+			if a.Type != "" {
+				attendee["type"] = a.Type
+			}
+			attendees[i] = attendee
 		}
 		fm["attendees"] = attendees
+		fm["attendee_count"] = len(event.Attendees)
+		fm["rsvp"] = rsvpCounts(event)
 	}
 
 	if event.IsOnlineMeeting && event.OnlineMeeting != nil && event.OnlineMeeting.JoinURL != "" {
@@ -107,10 +251,13 @@ func WriteEventFile(cfg *config.Config, account string, event *graph.Event, time
 		fm["categories"] = event.Categories
 	}
 
-	// Marshal frontmatter
-	fmData, err := yaml.Marshal(fm)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal frontmatter: %w", err)
+	if event.WebLink != "" {
+		fm["web_link"] = event.WebLink
+	}
+
+	// Record the original body format so it can be round-tripped
+	if event.Body != nil && event.Body.ContentType != "" {
+		fm["body_type"] = strings.ToLower(event.Body.ContentType)
 	}
 
 	// Convert body HTML to markdown
@@ -120,15 +267,151 @@ func WriteEventFile(cfg *config.Config, account string, event *graph.Event, time
 	}
 	body := graph.HTMLToMarkdown(bodyContent)
 
+	if cfg.MaxBodyBytes > 0 && len(body) > cfg.MaxBodyBytes {
+		body = truncateBytes(body, cfg.MaxBodyBytes) + "\n\n…[truncated]"
+		fm["body_truncated"] = true
+	}
+
+	// Keep keys the user added, e.g. tags
+	preserveUserKeys(filePath, fm, managedEventKeys)
+
+	// Marshal frontmatter
+	fmData, err := yaml.Marshal(fm)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal frontmatter: %w", err)
+	}
+
 	// Write file
 	content := fmt.Sprintf("---\n%s---\n\n# %s\n\n%s\n", string(fmData), event.Subject, body)
-	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+	if err := auth.AtomicWriteFile(filePath, []byte(content), 0644); err != nil {
 		return "", fmt.Errorf("failed to write file: %w", err)
 	}
 
 	return filePath, nil
 }
 
+// Frontmatter keys written by md365. Any other key in an existing file was
+// added by the user and survives rewrites; managed keys that no longer
+// apply (e.g. a removed location) are dropped.
+var (
+	managedEventKeys = keySet("id", "account", "subject", "start", "end", "all_day", "online_meeting",
+		"sensitivity", "last_modified", "generator", "schema_version", "redacted", "response", "location",
+		"organizer", "attendees", "attendee_count", "rsvp", "meeting_url", "categories", "web_link", "body_type", "body_truncated", "deleted")
+	managedContactKeys = keySet("id", "account", "display_name", "last_modified", "generator", "schema_version",
+		"given_name", "surname", "emails", "phones", "company", "job_title", "birthday", "deleted")
+)
+
+// matchesBase reports whether a file's base name is want, or want with the
+// "-N" suffix GenerateUniqueFilename adds on collisions
+func matchesBase(base, want string) bool {
+	if base == want {
+		return true
+	}
+	suffix, ok := strings.CutPrefix(base, want+"-")
+	if !ok || suffix == "" {
+		return false
+	}
+	for _, r := range suffix {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// rsvpCounts tallies attendee responses as accepted, declined, tentative
+// and noresponse. The organizer counts as accepted when listed.
+func rsvpCounts(event *graph.Event) map[string]int {
+	counts := map[string]int{"accepted": 0, "declined": 0, "tentative": 0, "noresponse": 0}
+	organizer := ""
+	if event.Organizer != nil {
+		organizer = event.Organizer.EmailAddress.Address
+	}
+	for _, a := range event.Attendees {
+		response := ""
+		if a.Status != nil {
+			response = a.Status.Response
+		}
+		switch {
+		case response == "accepted" || response == "organizer":
+			counts["accepted"]++
+		case response == "declined":
+			counts["declined"]++
+		case response == "tentativelyAccepted":
+			counts["tentative"]++
+		case organizer != "" && strings.EqualFold(a.EmailAddress.Address, organizer):
+			counts["accepted"]++
+		default:
+			counts["noresponse"]++
+		}
+	}
+	return counts
+}
+
+// keySet builds a lookup set of frontmatter keys
+func keySet(keys ...string) map[string]bool {
+	set := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		set[key] = true
+	}
+	return set
+}
+
+// preserveUserKeys copies the keys md365 does not manage from the
+// frontmatter of the existing file at path into fm
+func preserveUserKeys(path string, fm map[string]interface{}, managed map[string]bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	parts := strings.SplitN(string(data), "---", 3)
+	if len(parts) < 3 {
+		return
+	}
+
+	var existing map[string]interface{}
+	if err := yaml.Unmarshal([]byte(parts[1]), &existing); err != nil {
+		return
+	}
+	for key, value := range existing {
+		if _, set := fm[key]; !set && !managed[key] {
+			fm[key] = value
+		}
+	}
+}
+
+// truncateBytes cuts s to at most n bytes without splitting a UTF-8 character
+func truncateBytes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// isPrivate reports whether an event sensitivity is private or confidential
+func isPrivate(sensitivity string) bool {
+	return strings.EqualFold(sensitivity, "private") || strings.EqualFold(sensitivity, "confidential")
+}
+
+// redactEvent returns a copy of event with only its time, response and
+// sensitivity, for redact_private
+func redactEvent(event *graph.Event) *graph.Event {
+	return &graph.Event{
+		ID:                   event.ID,
+		Subject:              "Private appointment",
+		Start:                event.Start,
+		End:                  event.End,
+		IsAllDay:             event.IsAllDay,
+		ResponseStatus:       event.ResponseStatus,
+		Sensitivity:          event.Sensitivity,
+		LastModifiedDateTime: event.LastModifiedDateTime,
+		IsOrganizer:          event.IsOrganizer,
+	}
+}
+
 // WriteContactFile writes a contact to a markdown file
 func WriteContactFile(cfg *config.Config, account string, contact *graph.Contact) (string, error) {
 	contactDir := filepath.Join(cfg.DataDir, account, "contacts")
@@ -145,16 +428,30 @@ func WriteContactFile(cfg *config.Config, account string, contact *graph.Contact
 		if slug == "" {
 			slug = "unnamed"
 		}
-		filename := auth.GenerateUniqueFilename(contactDir, slug, ".md")
+		base, err := cfg.ContactFilename(config.FilenameData{
+			ID:      contact.ID,
+			Slug:    slug,
+			Name:    contact.DisplayName,
+			Account: account,
+		})
+		if err != nil {
+			return "", err
+		}
+		if base == "" {
+			base = "unnamed"
+		}
+		filename := auth.GenerateUniqueFilename(contactDir, base, ".md")
 		filePath = filepath.Join(contactDir, filename)
 	}
 
 	// Build frontmatter
 	fm := map[string]interface{}{
-		"id":            contact.ID,
-		"account":       account,
-		"display_name":  contact.DisplayName,
-		"last_modified": contact.LastModifiedDateTime,
+		"id":             contact.ID,
+		"account":        account,
+		"display_name":   contact.DisplayName,
+		"last_modified":  contact.LastModifiedDateTime,
+		"generator":      Generator,
+		"schema_version": SchemaVersion,
 	}
 
 	if contact.GivenName != "" {
@@ -196,6 +493,9 @@ func WriteContactFile(cfg *config.Config, account string, contact *graph.Contact
 		fm["birthday"] = contact.Birthday
 	}
 
+	// Keep keys the user added, e.g. tags
+	preserveUserKeys(filePath, fm, managedContactKeys)
+
 	// Marshal frontmatter
 	fmData, err := yaml.Marshal(fm)
 	if err != nil {
@@ -237,84 +537,268 @@ func WriteContactFile(cfg *config.Config, account string, contact *graph.Contact
 
 	// Write file
 	content := fmt.Sprintf("---\n%s---\n\n%s\n", string(fmData), body)
-	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+	if err := auth.AtomicWriteFile(filePath, []byte(content), 0644); err != nil {
 		return "", fmt.Errorf("failed to write file: %w", err)
 	}
 
 	return filePath, nil
 }
 
-// SyncCalendar syncs calendar events for an account
-func SyncCalendar(cfg *config.Config, account string, token string) error {
-	client := graph.NewClient(token)
+// ErrInterrupted is returned when a sync is stopped via its context.
+// Files written so far are complete; pruning and sync state are skipped.
+var ErrInterrupted = errors.New("sync interrupted")
+
+// SyncCalendar syncs calendar events for an account. The result is
+// returned even on error and holds the changes made until then.
+func SyncCalendar(ctx context.Context, cfg *config.Config, account string, client *graph.Client, opts Options) (*Result, error) {
 	calDir := filepath.Join(cfg.DataDir, account, "calendar")
+	result := &Result{}
+
+	opts.printf("Syncing calendar for account '%s'...\n", account)
 
-	fmt.Printf("Syncing calendar for account '%s'...\n", account)
+	if err := ensureDataDir(cfg); err != nil {
+		return nil, err
+	}
 
-	// Calculate date range: -30 days to +90 days
+	// Start from the previous calendar sync; the first sync uses the default
+	// window. LastSync is not used since a contacts-only success also sets it.
+	if opts.SinceLast && opts.Since.IsZero() {
+		if state, err := loadSyncState(cfg.DataDir, account); err == nil && state.CalendarSync != "" {
+			if lastSync, err := time.Parse(time.RFC3339, state.CalendarSync); err == nil {
+				opts.Since = lastSync
+				opts.printf("Fetching events from last sync at %s\n", state.CalendarSync)
+			}
+		}
+	}
+
+	// Calculate date range: -30 days (or --since) to +90 days
 	startDate := time.Now().AddDate(0, 0, -30)
+	if !opts.Since.IsZero() {
+		startDate = opts.Since
+	}
 	endDate := time.Now().AddDate(0, 0, 90)
 
 	events, err := client.GetCalendarView(startDate, endDate)
+	if ctx.Err() != nil {
+		return result, ErrInterrupted
+	}
 	if err != nil {
-		return fmt.Errorf("failed to get calendar view: %w", err)
+		return result, fmt.Errorf("failed to get calendar view: %w", err)
 	}
 
 	// Track which file path was written for each event ID
 	writtenPaths := make(map[string]string)
+	known := localIDs(calDir)
 
 	// Write events
 	for _, event := range events {
+		if ctx.Err() != nil {
+			return result, ErrInterrupted
+		}
+
 		path, err := WriteEventFile(cfg, account, &event, cfg.Timezone)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to write event %s: %v\n", event.ID, err)
+			result.addError(event.ID, "write event "+event.ID, err)
 			continue
 		}
 		writtenPaths[event.ID] = path
+
+		if known[event.ID] {
+			result.Updated++
+		} else {
+			result.New++
+		}
+	}
+
+	// An empty response while local files exist is most likely an auth or
+	// network hiccup, so don't treat it as "everything was deleted"
+	var pruneErr error
+	if opts.NoPrune {
+		markCalendarDeleted(calDir, writtenPaths, startDate, endDate, result)
+		opts.printf("Marked %d events as deleted for '%s'\n", result.Deleted, account)
+	} else if len(events) == 0 && !opts.AllowEmpty && hasMarkdownFiles(calDir) {
+		fmt.Fprintf(os.Stderr, "Warning: no events returned for '%s' but local events exist; skipping deletion (use --allow-empty to prune)\n", account)
+	} else {
+		pruneErr = pruneCalendar(calDir, account, writtenPaths, opts, result)
+	}
+
+	// Update sync state even if pruning was refused, since events were written.
+	// Events that failed to write keep --since-last-sync from moving past them.
+	if err := updateSyncState(cfg.DataDir, account, "", len(result.Errors) == 0); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to update sync state: %v\n", err)
+	}
+
+	if pruneErr != nil {
+		return result, pruneErr
 	}
 
-	// Delete files that are not the canonical path for any event
-	// This removes both stale events and duplicates
-	deleted := 0
+	opts.printf("Synced %d events for '%s' (new %d, updated %d, deleted %d)\n",
+		len(events), account, result.New, result.Updated, result.Deleted)
+	return result, nil
+}
+
+// localIDs returns the IDs found in the frontmatter of the markdown files in dir
+func localIDs(dir string) map[string]bool {
+	ids := make(map[string]bool)
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, ".md") {
+			return nil
+		}
+		if id, err := extractIDFromFile(path); err == nil && id != "" {
+			ids[id] = true
+		}
+		return nil
+	})
+	return ids
+}
+
+// pruneCalendar deletes calendar files that are not the canonical path for any
+// synced event, counting them in result
+func pruneCalendar(calDir, account string, writtenPaths map[string]string, opts Options, result *Result) error {
+	// Collect files that are not the canonical path for any event
+	// This covers both stale events and duplicates
+	var stale []string
+	existing := 0
 	if err := filepath.Walk(calDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil || info.IsDir() || !strings.HasSuffix(path, ".md") {
 			return nil
 		}
 
-		id, err := extractIDFromFile(path)
+		fm, err := readFrontmatter(path)
 		if err != nil {
 			return nil
 		}
 
+		id, ok := fm["id"].(string)
+		if !ok {
+			return nil
+		}
+
+		// Leave events before --since untouched
+		if !opts.Since.IsZero() {
+			startStr, _ := fm["start"].(string)
+			if start, err := time.Parse(time.RFC3339, startStr); err == nil && start.Before(opts.Since) {
+				return nil
+			}
+		}
+
+		existing++
 		canonicalPath, seen := writtenPaths[id]
 		if !seen || path != canonicalPath {
-			if err := os.Remove(path); err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: failed to delete %s: %v\n", path, err)
-			} else {
-				deleted++
-			}
+			stale = append(stale, path)
 		}
 
 		return nil
 	}); err != nil {
-		return fmt.Errorf("failed to walk calendar directory: %w", err)
+		// Prune what was found rather than aborting the calendar sync
+		fmt.Fprintf(os.Stderr, "Warning: failed to walk calendar directory: %v\n", err)
 	}
 
-	// Update sync state
-	if err := updateSyncState(cfg.DataDir, account, "", ""); err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: failed to update sync state: %v\n", err)
+	// Guard against wiping the calendar after a partial or empty API response
+	if !opts.Force && len(stale) >= pruneMinCount && float64(len(stale)) > float64(existing)*pruneMaxRatio {
+		return fmt.Errorf("refusing to delete %d of %d local event files for '%s'. Re-run with --force if this is expected",
+			len(stale), existing, account)
+	}
+
+	for _, path := range stale {
+		if err := os.Remove(path); err != nil {
+			result.addError("", "delete "+path, err)
+		} else {
+			result.Deleted++
+		}
 	}
 
-	fmt.Printf("Synced %d events for '%s' (deleted %d)\n", len(events), account, deleted)
 	return nil
 }
 
-// SyncContacts syncs contacts for an account
-func SyncContacts(cfg *config.Config, account string, token string) error {
-	client := graph.NewClient(token)
+// markCalendarDeleted stamps "deleted: true" on event files within the synced
+// window that are no longer returned by Graph, counting them in result
+func markCalendarDeleted(calDir string, writtenPaths map[string]string, from, to time.Time, result *Result) {
+	filepath.Walk(calDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, ".md") {
+			return nil
+		}
+
+		fm, err := readFrontmatter(path)
+		if err != nil {
+			return nil
+		}
+
+		id, _ := fm["id"].(string)
+		if _, seen := writtenPaths[id]; seen || id == "" {
+			return nil
+		}
+		if deleted, _ := fm["deleted"].(bool); deleted {
+			return nil
+		}
+
+		// Events outside the window were not fetched, so their absence means nothing
+		startStr, _ := fm["start"].(string)
+		start, err := time.Parse(time.RFC3339, startStr)
+		if err != nil || start.Before(from) || start.After(to) {
+			return nil
+		}
+
+		if err := markDeleted(path); err != nil {
+			result.addError(id, "mark "+path+" as deleted", err)
+		} else {
+			result.Deleted++
+		}
+		return nil
+	})
+}
+
+// markDeleted sets "deleted: true" in a file's frontmatter, keeping the body
+func markDeleted(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	parts := strings.SplitN(string(data), "---", 3)
+	if len(parts) < 3 {
+		return fmt.Errorf("invalid frontmatter")
+	}
+
+	var fm map[string]interface{}
+	if err := yaml.Unmarshal([]byte(parts[1]), &fm); err != nil {
+		return err
+	}
+	fm["deleted"] = true
+
+	fmData, err := yaml.Marshal(fm)
+	if err != nil {
+		return fmt.Errorf("failed to marshal frontmatter: %w", err)
+	}
+
+	content := fmt.Sprintf("---\n%s---%s", string(fmData), parts[2])
+	return auth.AtomicWriteFile(path, []byte(content), 0644)
+}
+
+// hasMarkdownFiles reports whether dir contains any markdown file
+func hasMarkdownFiles(dir string) bool {
+	found := false
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && strings.HasSuffix(path, ".md") {
+			found = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return found
+}
+
+// SyncContacts syncs contacts for an account. The result is returned even
+// on error and holds the changes made until then.
+func SyncContacts(ctx context.Context, cfg *config.Config, account string, client *graph.Client, opts Options) (*Result, error) {
 	contactDir := filepath.Join(cfg.DataDir, account, "contacts")
+	result := &Result{}
 
-	fmt.Printf("Syncing contacts for account '%s'...\n", account)
+	opts.printf("Syncing contacts for account '%s'...\n", account)
+
+	if err := ensureDataDir(cfg); err != nil {
+		return nil, err
+	}
 
 	// Load sync state
 	state, err := loadSyncState(cfg.DataDir, account)
@@ -322,78 +806,197 @@ func SyncContacts(cfg *config.Config, account string, token string) error {
 		state = &SyncState{}
 	}
 
+	// A full resync starts a new delta query from scratch
+	if opts.Full && state.ContactsDeltaLink != "" {
+		opts.printf("Full resync: ignoring stored contacts delta link\n")
+		state.ContactsDeltaLink = ""
+	}
+
 	// Get contacts using delta query
+	// On a failed page, contacts fetched so far are returned with a link to
+	// resume from, so that progress can still be saved
 	contacts, newDeltaLink, err := client.GetContactsDelta(state.ContactsDeltaLink)
-	if err != nil {
-		return fmt.Errorf("failed to get contacts: %w", err)
+	if ctx.Err() != nil {
+		return result, ErrInterrupted
 	}
 
-	newCount := 0
-	deletedCount := 0
+	// Graph can invalidate a delta link; drop it and start a full delta over
+	if graph.IsResyncRequired(err) && state.ContactsDeltaLink != "" {
+		fmt.Fprintf(os.Stderr, "Warning: contacts delta link for '%s' expired, doing a full resync\n", account)
+		if err := clearContactsDeltaLink(cfg.DataDir, account); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to update sync state: %v\n", err)
+		}
+
+		contacts, newDeltaLink, err = client.GetContactsDelta("")
+		if ctx.Err() != nil {
+			return result, ErrInterrupted
+		}
+	}
+	fetchErr := err
+	if fetchErr != nil && len(contacts) == 0 {
+		return result, fmt.Errorf("failed to get contacts: %w", fetchErr)
+	}
+
+	known := localIDs(contactDir)
 
 	// Process contacts
 	for _, contact := range contacts {
-		if contact.Removed != nil {
+		// Keep the old delta link so the next sync replays this batch
+		if ctx.Err() != nil {
+			return result, ErrInterrupted
+		}
+
+		if contact.Removed != nil && opts.NoPrune {
+			// Keep the file, but mark it as deleted
+			if path := findFileByID(contactDir, contact.ID); path != "" {
+				if err := markDeleted(path); err != nil {
+					result.addError(contact.ID, "mark contact "+contact.ID+" as deleted", err)
+				} else {
+					result.Deleted++
+				}
+			}
+		} else if contact.Removed != nil {
 			// Delete contact
 			if err := deleteContactByID(contactDir, contact.ID); err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: failed to delete contact %s: %v\n", contact.ID, err)
+				result.addError(contact.ID, "delete contact "+contact.ID, err)
 			} else {
-				deletedCount++
+				result.Deleted++
 			}
 		} else {
 			// New or updated contact
 			if _, err := WriteContactFile(cfg, account, &contact); err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: failed to write contact %s: %v\n", contact.ID, err)
+				result.addError(contact.ID, "write contact "+contact.ID, err)
+			} else if known[contact.ID] {
+				result.Updated++
 			} else {
-				newCount++
+				result.New++
 			}
 		}
 	}
 
+	// A complete full fetch lists every contact, so anything else is stale
+	var pruneErr error
+	if opts.Full && fetchErr == nil {
+		if len(contacts) == 0 && len(known) > 0 && !opts.AllowEmpty {
+			fmt.Fprintf(os.Stderr, "Warning: no contacts returned for '%s' but local contacts exist; skipping deletion (use --allow-empty to prune)\n", account)
+		} else {
+			pruneErr = pruneStaleContacts(contactDir, account, contacts, opts, result)
+		}
+	}
+
 	// Update sync state
-	if err := updateSyncState(cfg.DataDir, account, newDeltaLink, ""); err != nil {
+	if err := updateSyncState(cfg.DataDir, account, newDeltaLink, false); err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: failed to update sync state: %v\n", err)
 	}
 
-	fmt.Printf("Synced contacts for '%s' (new/updated: %d, deleted: %d)\n", account, newCount, deletedCount)
+	if fetchErr != nil {
+		return result, fmt.Errorf("failed to get all contacts (saved %d, will resume on next sync): %w", len(contacts), fetchErr)
+	}
+	if pruneErr != nil {
+		return result, pruneErr
+	}
+
+	opts.printf("Synced contacts for '%s' (new: %d, updated: %d, deleted: %d)\n", account, result.New, result.Updated, result.Deleted)
+	return result, nil
+}
+
+// pruneStaleContacts removes (or with NoPrune marks deleted) local contact
+// files whose ID is not among the fetched contacts. Files without a readable
+// ID are not md365's and are left alone.
+func pruneStaleContacts(contactDir, account string, contacts []graph.Contact, opts Options, result *Result) error {
+	current := make(map[string]bool, len(contacts))
+	for _, contact := range contacts {
+		if contact.Removed == nil {
+			current[contact.ID] = true
+		}
+	}
+
+	stale := make(map[string]string) // path -> id
+	existing := 0
+	filepath.Walk(contactDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, ".md") {
+			return nil
+		}
+		id, _ := extractIDFromFile(path)
+		if id == "" {
+			return nil
+		}
+		existing++
+		if !current[id] {
+			stale[path] = id
+		}
+		return nil
+	})
+
+	// Same guard as for the calendar: a short full fetch must not wipe contacts
+	if !opts.Force && len(stale) >= pruneMinCount && float64(len(stale)) > float64(existing)*pruneMaxRatio {
+		return fmt.Errorf("refusing to delete %d of %d local contact files for '%s'. Re-run with --force if this is expected",
+			len(stale), existing, account)
+	}
+
+	for path, id := range stale {
+		if opts.NoPrune {
+			if err := markDeleted(path); err != nil {
+				result.addError(id, "mark contact "+id+" as deleted", err)
+			} else {
+				result.Deleted++
+			}
+		} else if err := os.Remove(path); err != nil {
+			result.addError(id, "delete stale contact file "+path, err)
+		} else {
+			result.Deleted++
+		}
+	}
 	return nil
 }
 
+// FindEventFile returns the local file of an event of account, or "" if
+// there is none
+func FindEventFile(cfg *config.Config, account, id string) string {
+	return findFileByID(filepath.Join(cfg.DataDir, account, "calendar"), id)
+}
+
 // findFileByID finds an existing markdown file with the given ID in its frontmatter
 func findFileByID(dir, id string) string {
-	entries, err := os.ReadDir(dir)
-	if err != nil {
-		return ""
-	}
-	for _, entry := range entries {
-		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".md") {
-			continue
+	var found string
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, ".md") {
+			return nil
 		}
-		path := filepath.Join(dir, entry.Name())
-		fileID, err := extractIDFromFile(path)
-		if err == nil && fileID == id {
-			return path
+		if fileID, err := extractIDFromFile(path); err == nil && fileID == id {
+			found = path
+			return filepath.SkipAll
 		}
-	}
-	return ""
+		return nil
+	})
+	return found
 }
 
-// extractIDFromFile extracts the ID from a markdown file's frontmatter
-func extractIDFromFile(path string) (string, error) {
+// readFrontmatter parses the YAML frontmatter of a markdown file
+func readFrontmatter(path string) (map[string]interface{}, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// Parse frontmatter
 	content := string(data)
 	parts := strings.SplitN(content, "---", 3)
 	if len(parts) < 3 {
-		return "", fmt.Errorf("invalid frontmatter")
+		return nil, fmt.Errorf("invalid frontmatter")
 	}
 
 	var fm map[string]interface{}
 	if err := yaml.Unmarshal([]byte(parts[1]), &fm); err != nil {
+		return nil, err
+	}
+
+	return fm, nil
+}
+
+// extractIDFromFile extracts the ID from a markdown file's frontmatter
+func extractIDFromFile(path string) (string, error) {
+	fm, err := readFrontmatter(path)
+	if err != nil {
 		return "", err
 	}
 
@@ -443,8 +1046,26 @@ func loadSyncState(dataDir, account string) (*SyncState, error) {
 	return &state, nil
 }
 
-// updateSyncState updates the sync state for an account
-func updateSyncState(dataDir, account, deltaLink, lastSync string) error {
+// clearContactsDeltaLink removes the stored contacts delta link so the next
+// delta query starts from scratch
+func clearContactsDeltaLink(dataDir, account string) error {
+	state, err := loadSyncState(dataDir, account)
+	if err != nil {
+		return nil
+	}
+	state.ContactsDeltaLink = ""
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return auth.AtomicWriteFile(filepath.Join(dataDir, ".sync", account+".json"), data, 0644)
+}
+
+// updateSyncState updates the sync state for an account. calendarSynced also
+// records the time as the last successful calendar sync.
+func updateSyncState(dataDir, account, deltaLink string, calendarSynced bool) error {
 	syncDir := filepath.Join(dataDir, ".sync")
 	if err := os.MkdirAll(syncDir, 0755); err != nil {
 		return err
@@ -462,10 +1083,11 @@ func updateSyncState(dataDir, account, deltaLink, lastSync string) error {
 	if deltaLink != "" {
 		state.ContactsDeltaLink = deltaLink
 	}
-	if lastSync == "" {
-		lastSync = time.Now().UTC().Format(time.RFC3339)
+	now := time.Now().UTC().Format(time.RFC3339)
+	state.LastSync = now
+	if calendarSynced {
+		state.CalendarSync = now
 	}
-	state.LastSync = lastSync
 
 	// Save state
 	data, err := json.MarshalIndent(state, "", "  ")
@@ -473,38 +1095,95 @@ func updateSyncState(dataDir, account, deltaLink, lastSync string) error {
 		return err
 	}
 
-	return os.WriteFile(syncFile, data, 0644)
+	return auth.AtomicWriteFile(syncFile, data, 0644)
 }
 
-// convertGraphTimeToRFC3339 converts a Graph API DateTime+TimeZone pair to RFC3339 in the target timezone
+// locationCache holds the time zones loaded by LoadLocation, so a sync
+// reads each zone from the tz database only once
+var (
+	locationCache   = map[string]*time.Location{}
+	locationCacheMu gosync.Mutex
+)
+
+// LoadLocation is time.LoadLocation memoized per zone name
+func LoadLocation(name string) (*time.Location, error) {
+	locationCacheMu.Lock()
+	defer locationCacheMu.Unlock()
+
+	if loc, ok := locationCache[name]; ok {
+		return loc, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locationCache[name] = loc
+	return loc, nil
+}
+
+// ParseGraphTime converts a Graph API DateTime+TimeZone pair to a time in the target timezone
 // Graph API format: "2026-02-28T19:15:00.0000000" with separate "Europe/Berlin" timezone field
-func convertGraphTimeToRFC3339(dateTimeStr, sourceTimeZone, targetTimeZone string) (string, error) {
+func ParseGraphTime(dt graph.DateTime, targetTimeZone string) (time.Time, error) {
+	// Graph omits the zone on some all-day and imported events; their
+	// times are UTC like everything Graph returns without a Prefer header
+	sourceTimeZone := dt.TimeZone
+	if sourceTimeZone == "" {
+		graph.Debugf("time %s has no time zone, assuming UTC", dt.DateTime)
+		sourceTimeZone = "UTC"
+	}
+
 	// Load source timezone
-	sourceLoc, err := time.LoadLocation(sourceTimeZone)
+	sourceLoc, err := LoadLocation(sourceTimeZone)
 	if err != nil {
-		return "", fmt.Errorf("invalid source timezone %s: %w", sourceTimeZone, err)
+		return time.Time{}, fmt.Errorf("invalid source timezone %s: %w", dt.TimeZone, err)
 	}
 
 	// Load target timezone
-	targetLoc, err := time.LoadLocation(targetTimeZone)
+	targetLoc, err := LoadLocation(targetTimeZone)
 	if err != nil {
-		return "", fmt.Errorf("invalid target timezone %s: %w", targetTimeZone, err)
+		return time.Time{}, fmt.Errorf("invalid target timezone %s: %w", targetTimeZone, err)
 	}
 
-	// Parse the Graph API DateTime (without timezone info)
-	// Graph returns format like "2026-02-28T19:15:00.0000000"
-	t, err := time.ParseInLocation("2006-01-02T15:04:05.0000000", dateTimeStr, sourceLoc)
+	// Parse the Graph API DateTime (usually without timezone info)
+	t, err := parseGraphDateTime(dt.DateTime, sourceLoc)
 	if err != nil {
-		// Try without fractional seconds
-		t, err = time.ParseInLocation("2006-01-02T15:04:05", dateTimeStr, sourceLoc)
-		if err != nil {
-			return "", fmt.Errorf("failed to parse datetime %s: %w", dateTimeStr, err)
-		}
+		return time.Time{}, err
 	}
 
 	// Convert to target timezone
-	targetTime := t.In(targetLoc)
+	return t.In(targetLoc), nil
+}
+
+// convertGraphTimeToRFC3339 converts a Graph API DateTime+TimeZone pair to RFC3339 in the target timezone
+func convertGraphTimeToRFC3339(dateTimeStr, sourceTimeZone, targetTimeZone string) (string, error) {
+	t, err := ParseGraphTime(graph.DateTime{DateTime: dateTimeStr, TimeZone: sourceTimeZone}, targetTimeZone)
+	if err != nil {
+		return "", err
+	}
 
 	// Format as RFC3339
-	return targetTime.Format(time.RFC3339), nil
+	return t.Format(time.RFC3339), nil
+}
+
+// graphDateTimeLayouts are the layouts accepted for Graph DateTime values.
+// Go accepts any fractional-second precision after the seconds field when
+// parsing, so these cover "...05", "...05.000", "...05.0000000" and a
+// trailing "Z" or offset.
+var graphDateTimeLayouts = []string{
+	"2006-01-02T15:04:05",
+	time.RFC3339Nano,
+}
+
+// parseGraphDateTime parses a Graph DateTime string such as
+// "2026-02-28T19:15:00.0000000", interpreting values without an offset in loc
+func parseGraphDateTime(dateTimeStr string, loc *time.Location) (time.Time, error) {
+	var lastErr error
+	for _, layout := range graphDateTimeLayouts {
+		t, err := time.ParseInLocation(layout, dateTimeStr, loc)
+		if err == nil {
+			return t, nil
+		}
+		lastErr = err
+	}
+	return time.Time{}, fmt.Errorf("failed to parse datetime %s: %w", dateTimeStr, lastErr)
 }
@@ -0,0 +1,41 @@
+package sync
+
+import "testing"
+
+func TestConvertGraphTimeToRFC3339(t *testing.T) {
+	tests := []struct {
+		name     string
+		dateTime string
+		zone     string
+		want     string
+	}{
+		{"no fraction", "2026-02-28T19:15:00", "UTC", "2026-02-28T20:15:00+01:00"},
+		{"3 fractional digits", "2026-02-28T19:15:00.000", "UTC", "2026-02-28T20:15:00+01:00"},
+		{"7 fractional digits", "2026-02-28T19:15:00.0000000", "UTC", "2026-02-28T20:15:00+01:00"},
+		{"trailing Z", "2026-02-28T19:15:00.0000000Z", "UTC", "2026-02-28T20:15:00+01:00"},
+		{"offset", "2026-02-28T19:15:00+02:00", "UTC", "2026-02-28T18:15:00+01:00"},
+		{"source zone", "2026-07-01T09:00:00.0000000", "America/New_York", "2026-07-01T15:00:00+02:00"},
+		{"empty zone is UTC", "2026-02-28T19:15:00", "", "2026-02-28T20:15:00+01:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := convertGraphTimeToRFC3339(tt.dateTime, tt.zone, "Europe/Berlin")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("convertGraphTimeToRFC3339(%q, %q) = %q, want %q", tt.dateTime, tt.zone, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertGraphTimeToRFC3339Invalid(t *testing.T) {
+	if _, err := convertGraphTimeToRFC3339("28.02.2026 19:15", "UTC", "Europe/Berlin"); err == nil {
+		t.Error("expected an error for a malformed datetime")
+	}
+	if _, err := convertGraphTimeToRFC3339("2026-02-28T19:15:00", "Not/AZone", "Europe/Berlin"); err == nil {
+		t.Error("expected an error for an unknown source zone")
+	}
+}
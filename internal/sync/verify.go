@@ -0,0 +1,92 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lcorneliussen/md365/internal/config"
+)
+
+// VerifyResult lists the problems Verify found in the data directory
+type VerifyResult struct {
+	Files    int       `json:"files"`
+	Problems []Problem `json:"problems,omitempty"`
+}
+
+// Problem is one inconsistency in a synced file
+type Problem struct {
+	File    string `json:"file"`
+	Problem string `json:"problem"`
+}
+
+// Verify checks the event and contact files of the given accounts: every
+// file has parseable frontmatter with an id, no id appears in more than one
+// file, and event start and end times parse. Files are only read.
+func Verify(cfg *config.Config, accounts []string) (*VerifyResult, error) {
+	result := &VerifyResult{}
+	files := make(map[string][]string) // id -> files
+
+	for _, account := range accounts {
+		for _, kind := range []string{"calendar", "contacts"} {
+			dir := filepath.Join(cfg.DataDir, account, kind)
+			if _, err := os.Stat(dir); os.IsNotExist(err) {
+				continue
+			}
+
+			err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() || !strings.HasSuffix(path, ".md") {
+					return nil
+				}
+				result.Files++
+
+				fm, err := readFrontmatter(path)
+				if err != nil {
+					result.add(path, fmt.Sprintf("invalid frontmatter: %v", err))
+					return nil
+				}
+
+				id, _ := fm["id"].(string)
+				if id == "" {
+					result.add(path, "no id in frontmatter")
+				} else {
+					files[id] = append(files[id], path)
+				}
+
+				if kind == "calendar" {
+					for _, key := range []string{"start", "end"} {
+						value, _ := fm[key].(string)
+						if _, err := time.Parse(time.RFC3339, value); err != nil {
+							result.add(path, fmt.Sprintf("invalid %s %q", key, value))
+						}
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return result, fmt.Errorf("failed to walk %s: %w", dir, err)
+			}
+		}
+	}
+
+	for id, paths := range files {
+		if len(paths) > 1 {
+			for _, path := range paths {
+				result.add(path, fmt.Sprintf("duplicate id %s (%d files)", id, len(paths)))
+			}
+		}
+	}
+
+	sort.SliceStable(result.Problems, func(i, j int) bool {
+		return result.Problems[i].File < result.Problems[j].File
+	})
+	return result, nil
+}
+
+// add records a problem with a file
+func (r *VerifyResult) add(path, problem string) {
+	r.Problems = append(r.Problems, Problem{File: path, Problem: problem})
+}